@@ -15,19 +15,34 @@ package main
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/codahale/hdrhistogram"
 	"github.com/dustin/go-humanize"
 	"github.com/gosuri/uiprogress"
+	"github.com/nats-io/jsm.go"
+	"github.com/nats-io/jsm.go/api"
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/bench"
+	histwriter "github.com/tylertreat/hdrhistogram-writer"
+	"github.com/xlab/tablewriter"
+	"golang.org/x/crypto/ssh/terminal"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
+const (
+	benchPullConsumer = "NATS_BENCH_PULL"
+	benchSentHeader   = "Nats-Bench-Sent"
+)
+
 type benchCmd struct {
 	subject  string
 	numPubs  int
@@ -37,11 +52,45 @@ type benchCmd struct {
 	csvFile  string
 	progress bool
 	ack      bool
+	request  bool
+	histFile string
+	pull     bool
+	batch    int
+	stream   string
+	keep     bool
+	liveRate bool
+	oneWay   bool
+
+	published int64
+	received  int64
+	skewed    int64
+
+	mu           sync.Mutex
+	latencies    *hdrhistogram.Histogram
+	subLatencies *hdrhistogram.Histogram
+	ackLatencies *hdrhistogram.Histogram
 }
 
 func configureBenchCommand(app *kingpin.Application) {
 	c := &benchCmd{}
-	bench := app.Command("bench", "Benchmark utility").Action(c.bench)
+	help := `Benchmark utility
+
+One-way delivery latency is measured with --latency, publishers add their
+send time to every message in the Nats-Bench-Sent header and subscribers
+report how long after that time messages were received.
+
+Publishers and subscribers can run on different machines by starting the
+subscribers first with --pub 0 and the same --msgs as the publishers:
+
+   nats bench test --sub 2 --pub 0 --msgs 100000 --latency
+   nats bench test --sub 0 --pub 1 --msgs 100000 --latency
+
+Latencies across machines are only accurate when their clocks are closely
+synchronized, for example using PTP, as any clock offset is added to or
+removed from every measurement.
+`
+
+	bench := app.Command("bench", help).Action(c.bench)
 	bench.Arg("subject", "Subject to use for testing").Required().StringVar(&c.subject)
 	bench.Flag("pub", "Number of concurrent publishers").Default("1").IntVar(&c.numPubs)
 	bench.Flag("sub", "Number of concurrent subscribers").Default("0").IntVar(&c.numSubs)
@@ -50,6 +99,14 @@ func configureBenchCommand(app *kingpin.Application) {
 	bench.Flag("csv", "Save benchmark data to CSV file").StringVar(&c.csvFile)
 	bench.Flag("progress", "Enable progress bar while publishing").Default("true").BoolVar(&c.progress)
 	bench.Flag("ack", "Waits for acknowledgement on messages using Requests rather than Publish").Default("false").BoolVar(&c.ack)
+	bench.Flag("request", "Measures request-reply round trip times, subscribers act as responders").Default("false").BoolVar(&c.request)
+	bench.Flag("histogram-file", "Save a HDR histogram of message latencies to this file").PlaceHolder("FILE").StringVar(&c.histFile)
+	bench.Flag("pull", "Subscribers consume from a JetStream pull Consumer").Default("false").BoolVar(&c.pull)
+	bench.Flag("batch", "Number of messages to fetch per pull request").Default("100").IntVar(&c.batch)
+	bench.Flag("stream", "Stream to create the pull Consumer on").StringVar(&c.stream)
+	bench.Flag("keep", "Do not remove the pull Consumer after the benchmark").Default("false").BoolVar(&c.keep)
+	bench.Flag("latency", "Measures one-way delivery latency to subscribers using a send time header").Default("false").BoolVar(&c.oneWay)
+	bench.Flag("live-rate", "Show the aggregate message and byte rates every second while running, replaces the progress bars").Default("false").BoolVar(&c.liveRate)
 }
 
 func (c *benchCmd) bench(_ *kingpin.ParseContext) error {
@@ -57,20 +114,83 @@ func (c *benchCmd) bench(_ *kingpin.ParseContext) error {
 		return fmt.Errorf("number of messages should be greater than 0")
 	}
 
+	if c.pull && c.stream == "" {
+		return fmt.Errorf("pull mode requires a Stream to be set using --stream")
+	}
+
+	if c.pull && c.request {
+		return fmt.Errorf("pull and request modes can not be combined")
+	}
+
+	if c.pull && c.batch < 1 {
+		return fmt.Errorf("batch size should be greater than 0")
+	}
+
+	if c.oneWay && c.request {
+		return fmt.Errorf("latency measures delivery to subscribers and can not be combined with request mode")
+	}
+
+	if c.histFile != "" && !c.ack && !c.request && !c.oneWay && c.msgSize < 8 {
+		return fmt.Errorf("message size should be at least 8 bytes to measure latency for the histogram")
+	}
+
 	log.Printf("Starting benchmark [msgs=%s, msgsize=%s, pubs=%d, subs=%d]", humanize.Comma(int64(c.numMsg)), humanize.IBytes(uint64(c.msgSize)), c.numPubs, c.numSubs)
 
-	if c.ack && c.progress {
+	if (c.ack || c.request) && c.progress {
 		log.Printf("Disabling progress bars in request mode")
 		c.progress = false
 	}
 
-	bm := bench.NewBenchmark("NATS", c.numSubs, c.numPubs)
+	if c.liveRate && !terminal.IsTerminal(int(os.Stdout.Fd())) {
+		log.Printf("Disabling live rates as output is not a terminal")
+		c.liveRate = false
+	}
+
+	if c.liveRate {
+		c.progress = false
+	}
+
+	subs := c.numSubs
+	if c.request {
+		subs = 0
+	}
+
+	bm := bench.NewBenchmark("NATS", subs, c.numPubs)
+
+	c.latencies = newLatencyHistogram()
+	c.subLatencies = newLatencyHistogram()
+	c.ackLatencies = newLatencyHistogram()
 
 	startwg := &sync.WaitGroup{}
 	donewg := &sync.WaitGroup{}
 
-	for i := 0; i < c.numSubs; i++ {
-		nc, err := nats.Connect(config.ServerURL(), natsOpts()...)
+	for i := 0; c.request && i < c.numSubs; i++ {
+		nc, err := newNatsConn("", natsOpts()...)
+		if err != nil {
+			return fmt.Errorf("nats connection %d failed: %s", i, err)
+		}
+		defer nc.Close()
+
+		err = c.runResponder(nc)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.pull {
+		consumer, err := c.createPullConsumer()
+		if err != nil {
+			return err
+		}
+
+		if !c.keep {
+			defer consumer.Delete()
+		}
+	}
+
+	pullCounts := bench.MsgsPerClient(c.numMsg, subs)
+	for i := 0; i < subs; i++ {
+		nc, err := newNatsConn("", natsOpts()...)
 		if err != nil {
 			return fmt.Errorf("nats connection %d failed: %s", i, err)
 		}
@@ -79,18 +199,26 @@ func (c *benchCmd) bench(_ *kingpin.ParseContext) error {
 		startwg.Add(1)
 		donewg.Add(1)
 
-		go c.runSubscriber(bm, nc, startwg, donewg)
+		if c.pull {
+			go c.runPuller(bm, nc, startwg, donewg, pullCounts[i])
+		} else {
+			go c.runSubscriber(bm, nc, startwg, donewg)
+		}
 	}
 	startwg.Wait()
 
 	pubCounts := bench.MsgsPerClient(c.numMsg, c.numPubs)
 	for i := 0; i < c.numPubs; i++ {
-		nc, err := nats.Connect(config.ServerURL(), natsOpts()...)
+		nc, err := newNatsConn("", natsOpts()...)
 		if err != nil {
 			return fmt.Errorf("nats connection %d failed: %s", i, err)
 		}
 		defer nc.Close()
 
+		if c.oneWay && !nc.HeadersSupported() {
+			return fmt.Errorf("latency measurement requires a server that supports headers")
+		}
+
 		startwg.Add(1)
 		donewg.Add(1)
 
@@ -101,6 +229,12 @@ func (c *benchCmd) bench(_ *kingpin.ParseContext) error {
 		uiprogress.Start()
 	}
 
+	stopRate := make(chan struct{})
+	rateDone := make(chan struct{})
+	if c.liveRate {
+		go c.showLiveRate(stopRate, rateDone)
+	}
+
 	startwg.Wait()
 	donewg.Wait()
 
@@ -110,9 +244,37 @@ func (c *benchCmd) bench(_ *kingpin.ParseContext) error {
 		uiprogress.Stop()
 	}
 
+	if c.liveRate {
+		close(stopRate)
+		<-rateDone
+	}
+
 	fmt.Println()
 	fmt.Println(bm.Report())
 
+	if c.latencies.TotalCount() > 0 {
+		c.showLatencies("Request Latency", c.latencies)
+	}
+
+	if c.ackLatencies.TotalCount() > 0 {
+		c.showLatencies("Ack Latency", c.ackLatencies)
+	}
+
+	if c.subLatencies.TotalCount() > 0 {
+		c.showLatencies("Message Latency", c.subLatencies)
+	}
+
+	if skewed := atomic.LoadInt64(&c.skewed); skewed > 0 {
+		log.Printf("%s messages were received before they were sent and not included in latencies, check clock synchronization", humanize.Comma(skewed))
+	}
+
+	if c.histFile != "" {
+		err := c.writeHistogram()
+		if err != nil {
+			return err
+		}
+	}
+
 	if c.csvFile != "" {
 		csv := bm.CSV()
 		ioutil.WriteFile(c.csvFile, []byte(csv), 0644)
@@ -145,21 +307,42 @@ func (c *benchCmd) runPublisher(bm *bench.Benchmark, nc *nats.Conn, startwg *syn
 	errBytes := []byte("error")
 	minusByte := byte('-')
 
+	latencies := newLatencyHistogram()
+
 	for i := 0; i < numMsg; i++ {
 		if progress != nil {
 			progress.Incr()
 		}
 
-		if !c.ack {
-			nc.Publish(c.subject, msg)
+		if c.histFile != "" && !c.oneWay && len(msg) >= 8 {
+			binary.LittleEndian.PutUint64(msg, uint64(time.Now().UnixNano()))
+		}
+
+		out := &nats.Msg{Subject: c.subject, Data: msg}
+		if c.oneWay {
+			out = nats.NewMsg(c.subject)
+			out.Data = msg
+			out.Header.Set(benchSentHeader, strconv.FormatInt(time.Now().UnixNano(), 10))
+		}
+
+		if !c.ack && !c.request {
+			nc.PublishMsg(out)
+			atomic.AddInt64(&c.published, 1)
 			continue
 		}
 
-		m, err = nc.Request(c.subject, msg, time.Second)
+		rstart := time.Now()
+		m, err = nc.RequestMsg(out, time.Second)
 		if err != nil {
 			log.Println(err)
 			continue
 		}
+		recordLatency(latencies, time.Since(rstart))
+		atomic.AddInt64(&c.published, 1)
+
+		if c.request {
+			continue
+		}
 
 		if len(m.Data) == 0 || m.Data[0] == minusByte || bytes.Contains(m.Data, errBytes) {
 			log.Printf("Did not receive a positive ACK: %q", m.Data)
@@ -168,6 +351,8 @@ func (c *benchCmd) runPublisher(bm *bench.Benchmark, nc *nats.Conn, startwg *syn
 
 	nc.Flush()
 
+	c.mergeLatencies(c.latencies, latencies)
+
 	bm.AddPubSample(bench.NewSample(numMsg, c.msgSize, start, time.Now(), nc))
 
 	donewg.Done()
@@ -176,12 +361,19 @@ func (c *benchCmd) runPublisher(bm *bench.Benchmark, nc *nats.Conn, startwg *syn
 func (c *benchCmd) runSubscriber(bm *bench.Benchmark, nc *nats.Conn, startwg *sync.WaitGroup, donewg *sync.WaitGroup) {
 	received := 0
 	ch := make(chan time.Time, 2)
+	latencies := newLatencyHistogram()
 
 	sub, _ := nc.Subscribe(c.subject, func(msg *nats.Msg) {
+		c.recordDelivery(latencies, msg)
+
+		atomic.AddInt64(&c.received, 1)
 		received++
 		if received == 1 {
 			ch <- time.Now()
 		}
+		if received == c.numMsg {
+			c.mergeLatencies(c.subLatencies, latencies)
+		}
 		if received >= c.numMsg {
 			ch <- time.Now()
 		}
@@ -199,3 +391,230 @@ func (c *benchCmd) runSubscriber(bm *bench.Benchmark, nc *nats.Conn, startwg *sy
 	nc.Close()
 	donewg.Done()
 }
+
+func (c *benchCmd) createPullConsumer() (*jsm.Consumer, error) {
+	_, mgr, err := prepareHelper("", natsOpts()...)
+	if err != nil {
+		return nil, err
+	}
+
+	known, err := mgr.IsKnownConsumer(c.stream, benchPullConsumer)
+	if err != nil {
+		return nil, err
+	}
+
+	if known {
+		return nil, fmt.Errorf("consumer %s > %s already exists, remove it before running the benchmark", c.stream, benchPullConsumer)
+	}
+
+	return mgr.NewConsumer(c.stream, jsm.DurableName(benchPullConsumer), jsm.FilterStreamBySubject(c.subject), jsm.AcknowledgeExplicit())
+}
+
+func (c *benchCmd) runPuller(bm *bench.Benchmark, nc *nats.Conn, startwg *sync.WaitGroup, donewg *sync.WaitGroup, numMsg int) {
+	defer donewg.Done()
+
+	mgr, err := jsm.New(nc)
+	if err != nil {
+		startwg.Done()
+		log.Printf("Pull worker could not start: %s", err)
+		return
+	}
+
+	sub, err := nc.SubscribeSync(nats.NewInbox())
+	if err != nil {
+		startwg.Done()
+		log.Printf("Pull worker could not start: %s", err)
+		return
+	}
+	sub.SetPendingLimits(-1, -1)
+	startwg.Done()
+
+	var start time.Time
+	received := 0
+	latencies := newLatencyHistogram()
+	ackLatencies := newLatencyHistogram()
+
+	defer func() {
+		c.mergeLatencies(c.subLatencies, latencies)
+		c.mergeLatencies(c.ackLatencies, ackLatencies)
+	}()
+
+	for received < numMsg {
+		batch := c.batch
+		if numMsg-received < batch {
+			batch = numMsg - received
+		}
+
+		err = mgr.NextMsgRequest(c.stream, benchPullConsumer, sub.Subject, &api.JSApiConsumerGetNextRequest{Batch: batch, Expires: time.Now().Add(timeout)})
+		if err != nil {
+			log.Printf("Pull request failed: %s", err)
+			break
+		}
+
+		var m *nats.Msg
+		for i := 0; i < batch; i++ {
+			m, err = sub.NextMsg(timeout)
+			if err != nil {
+				break
+			}
+
+			c.recordDelivery(latencies, m)
+
+			if received == 0 {
+				start = time.Now()
+			}
+			received++
+			atomic.AddInt64(&c.received, 1)
+
+			astart := time.Now()
+			_, aerr := nc.Request(m.Reply, nil, timeout)
+			if aerr != nil {
+				log.Printf("Acknowledgement failed: %s", aerr)
+				continue
+			}
+			recordLatency(ackLatencies, time.Since(astart))
+		}
+
+		if err != nil {
+			log.Printf("Pull worker stopped after %s messages: %s", humanize.Comma(int64(received)), err)
+			break
+		}
+	}
+
+	if received > 0 {
+		bm.AddSubSample(bench.NewSample(received, c.msgSize, start, time.Now(), nc))
+	}
+}
+
+func (c *benchCmd) runResponder(nc *nats.Conn) error {
+	sub, err := nc.QueueSubscribe(c.subject, "NATS-BENCH", func(msg *nats.Msg) {
+		msg.Respond(msg.Data)
+	})
+	if err != nil {
+		return err
+	}
+
+	sub.SetPendingLimits(-1, -1)
+
+	return nc.Flush()
+}
+
+// showLiveRate updates a single line with the publish and receive rates of the last second until stop is closed
+func (c *benchCmd) showLiveRate(stop chan struct{}, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var lastPub, lastRecv int64
+	rate := func(msgs int64) string {
+		return fmt.Sprintf("%s msgs/sec ~ %s/sec", humanize.Comma(msgs), humanize.IBytes(uint64(msgs*int64(c.msgSize))))
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+			pub := atomic.LoadInt64(&c.published)
+			recv := atomic.LoadInt64(&c.received)
+
+			line := fmt.Sprintf("Pub: %s", rate(pub-lastPub))
+			if c.numSubs > 0 && !c.request {
+				line = fmt.Sprintf("%s  Sub: %s", line, rate(recv-lastRecv))
+			}
+			fmt.Printf("\r\033[K%s", line)
+
+			lastPub, lastRecv = pub, recv
+
+		case <-stop:
+			fmt.Println()
+			return
+		}
+	}
+}
+
+// benchMaxLatency is the highest latency tracked in histograms, slower responses are recorded as this value
+const benchMaxLatency = 10 * time.Minute
+
+func newLatencyHistogram() *hdrhistogram.Histogram {
+	return hdrhistogram.New(1, int64(benchMaxLatency), 3)
+}
+
+func recordLatency(h *hdrhistogram.Histogram, d time.Duration) {
+	if d > benchMaxLatency {
+		d = benchMaxLatency
+	}
+
+	h.RecordValue(int64(d))
+}
+
+// mergeLatencies adds the latencies gathered by a single worker to the totals for the benchmark
+func (c *benchCmd) mergeLatencies(total *hdrhistogram.Histogram, worker *hdrhistogram.Histogram) {
+	c.mu.Lock()
+	total.Merge(worker)
+	c.mu.Unlock()
+}
+
+// recordDelivery records the latency of a received message based on the send time header or the timestamp in the body
+func (c *benchCmd) recordDelivery(h *hdrhistogram.Histogram, msg *nats.Msg) {
+	var sent time.Time
+
+	switch {
+	case c.oneWay:
+		ns, err := strconv.ParseInt(msg.Header.Get(benchSentHeader), 10, 64)
+		if err != nil {
+			return
+		}
+		sent = time.Unix(0, ns)
+
+	case c.histFile != "" && len(msg.Data) >= 8:
+		sent = time.Unix(0, int64(binary.LittleEndian.Uint64(msg.Data)))
+
+	default:
+		return
+	}
+
+	d := time.Since(sent)
+	if d < 0 {
+		atomic.AddInt64(&c.skewed, 1)
+		return
+	}
+
+	recordLatency(h, d)
+}
+
+// writeHistogram saves the request latencies, or when not requesting the subscriber latencies, in hgrm format with values in milliseconds
+func (c *benchCmd) writeHistogram() error {
+	latencies := c.latencies
+	if latencies.TotalCount() == 0 {
+		latencies = c.ackLatencies
+	}
+	if latencies.TotalCount() == 0 {
+		latencies = c.subLatencies
+	}
+
+	if latencies.TotalCount() == 0 {
+		return fmt.Errorf("no latency data was gathered, use --request, --ack or subscribers to produce a histogram")
+	}
+
+	pctls := histwriter.Percentiles{10, 25, 50, 75, 90, 95, 99, 99.9, 99.99, 99.999, 99.9999, 99.99999, 100.0}
+	err := histwriter.WriteDistributionFile(latencies, pctls, 1.0/1000000.0, c.histFile)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Saved latency histogram in file %s\n", c.histFile)
+
+	return nil
+}
+
+func (c *benchCmd) showLatencies(title string, h *hdrhistogram.Histogram) {
+	pct := func(q float64) time.Duration {
+		return time.Duration(h.ValueAtQuantile(q)).Round(time.Microsecond)
+	}
+
+	table := tablewriter.CreateTable()
+	table.AddTitle(title)
+	table.AddHeaders("Messages", "Min", "p50", "p90", "p99", "Max")
+	table.AddRow(humanize.Comma(h.TotalCount()), time.Duration(h.Min()).Round(time.Microsecond), pct(50), pct(90), pct(99), time.Duration(h.Max()).Round(time.Microsecond))
+	fmt.Println(table.Render())
+}
@@ -0,0 +1,203 @@
+// Copyright 2020 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/fatih/color"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type connCmd struct{}
+
+func configureConnectionCommand(app *kingpin.Application) {
+	c := &connCmd{}
+
+	conn := app.Command("connection", "Connection diagnostics").Alias("conn")
+	conn.Command("info", "Shows details about the connection made using the current context").Alias("i").Action(c.infoAction)
+}
+
+func (c *connCmd) infoAction(_ *kingpin.ParseContext) error {
+	opts := natsOpts()
+
+	nc, err := newNatsConn("", opts...)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	rtt, err := nc.RTT()
+	if err != nil {
+		return err
+	}
+
+	info, tlsState, err := c.probe(nc, opts)
+	if err != nil {
+		return err
+	}
+
+	bold := color.New(color.Bold).SprintFunc()
+
+	fmt.Printf("Connection information for %s\n\n", bold(nc.ConnectedUrl()))
+
+	fmt.Printf("%s\n\n", bold("Server Details:"))
+	fmt.Printf("          Address: %s\n", nc.ConnectedAddr())
+	fmt.Printf("        Server ID: %s\n", nc.ConnectedServerId())
+	if name := nc.ConnectedServerName(); name != "" && name != nc.ConnectedServerId() {
+		fmt.Printf("      Server Name: %s\n", name)
+	}
+	fmt.Printf("          Version: %s\n", info.Version)
+	if cluster := nc.ConnectedClusterName(); cluster != "" {
+		fmt.Printf("          Cluster: %s\n", cluster)
+	}
+	fmt.Printf("        JetStream: %v\n", info.JetStream)
+	fmt.Printf("          Headers: %v\n", nc.HeadersSupported())
+	fmt.Printf("      Max Payload: %s\n", humanize.IBytes(uint64(nc.MaxPayload())))
+	fmt.Printf("              RTT: %v\n", rtt)
+
+	fmt.Println()
+	fmt.Printf("%s\n\n", bold("Security Details:"))
+	fmt.Printf("    Auth Required: %v\n", nc.AuthRequired())
+	fmt.Printf("      Auth Method: %s\n", c.authMethod(nc))
+	fmt.Printf("     TLS Required: %v\n", nc.TLSRequired())
+
+	if tlsState != nil {
+		fmt.Printf("      TLS Version: %s\n", tlsVersionName(tlsState.Version))
+		fmt.Printf("       TLS Cipher: %s\n", tls.CipherSuiteName(tlsState.CipherSuite))
+
+		for i, cert := range tlsState.PeerCertificates {
+			if i == 0 {
+				fmt.Printf("Peer Certificates: %s\n", cert.Subject)
+			} else {
+				fmt.Printf("                   %s\n", cert.Subject)
+			}
+			fmt.Printf("                     issuer: %s\n", cert.Issuer)
+			fmt.Printf("                    expires: %s (%s)\n", cert.NotAfter.Format(time.RFC3339), humanizeDuration(time.Until(cert.NotAfter)))
+		}
+	}
+
+	fmt.Println()
+
+	return nil
+}
+
+// probe makes a second connection to the server nc is connected to, reads its INFO and performs the TLS handshake if required
+func (c *connCmd) probe(nc *nats.Conn, opts []nats.Option) (*server.Info, *tls.ConnectionState, error) {
+	conn, err := net.DialTimeout("tcp", nc.ConnectedAddr(), timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read server INFO: %s", err)
+	}
+
+	if !strings.HasPrefix(line, "INFO ") {
+		return nil, nil, fmt.Errorf("invalid server INFO received: %q", line)
+	}
+
+	info := &server.Info{}
+	err = json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), info)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid server INFO received: %s", err)
+	}
+
+	if !info.TLSRequired {
+		return info, nil, nil
+	}
+
+	o := nats.GetDefaultOptions()
+	for _, opt := range opts {
+		err = opt(&o)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	tlsc := &tls.Config{MinVersion: tls.VersionTLS12}
+	if o.TLSConfig != nil {
+		tlsc = o.TLSConfig.Clone()
+	}
+
+	if tlsc.ServerName == "" {
+		u, err := url.Parse(nc.ConnectedUrl())
+		if err == nil {
+			tlsc.ServerName = u.Hostname()
+		}
+	}
+
+	tconn := tls.Client(conn, tlsc)
+	err = tconn.Handshake()
+	if err != nil {
+		return nil, nil, fmt.Errorf("TLS handshake failed: %s", err)
+	}
+
+	state := tconn.ConnectionState()
+
+	return info, &state, nil
+}
+
+// authMethod describes the credentials that were used to connect which all succeeded since the connection is up
+func (c *connCmd) authMethod(nc *nats.Conn) string {
+	switch {
+	case !nc.AuthRequired():
+		return "None"
+	case userJWT != "":
+		return "JWT with NKEY seed"
+	case nkeySeed != "":
+		return "NKEY seed"
+	case config.Creds() != "":
+		return fmt.Sprintf("Credentials file %s", config.Creds())
+	case config.NKey() != "":
+		return fmt.Sprintf("NKEY file %s", config.NKey())
+	case config.User() != "" && config.Password() != "":
+		return fmt.Sprintf("User %s and password", config.User())
+	case config.User() != "":
+		return fmt.Sprintf("User %s without password", config.User())
+	case config.Certificate() != "":
+		return fmt.Sprintf("TLS client certificate %s", config.Certificate())
+	default:
+		return "Unknown, perhaps credentials in the server URL"
+	}
+}
+
+func tlsVersionName(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "1.0"
+	case tls.VersionTLS11:
+		return "1.1"
+	case tls.VersionTLS12:
+		return "1.2"
+	case tls.VersionTLS13:
+		return "1.3"
+	default:
+		return fmt.Sprintf("unknown (0x%04x)", v)
+	}
+}
@@ -19,15 +19,20 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/dustin/go-humanize"
+	"github.com/guptarohit/asciigraph"
 	"github.com/nats-io/jsm.go/api"
 	"github.com/nats-io/nats.go"
+	"github.com/xlab/tablewriter"
+	"golang.org/x/crypto/ssh/terminal"
 	"gopkg.in/alecthomas/kingpin.v2"
 
 	"github.com/nats-io/jsm.go"
@@ -57,6 +62,10 @@ type consumerCmd struct {
 	delivery      string
 	ephemeral     bool
 	validateOnly  bool
+	rmAll         bool
+	pretty        bool
+	translate     string
+	watch         bool
 
 	mgr *jsm.Manager
 	nc  *nats.Conn
@@ -86,7 +95,7 @@ func configureConsumerCommand(app *kingpin.Application) {
 	consAdd := cons.Command("add", "Creates a new Consumer").Alias("create").Alias("new").Action(c.createAction)
 	consAdd.Arg("stream", "Stream name").StringVar(&c.stream)
 	consAdd.Arg("consumer", "Consumer name").StringVar(&c.consumer)
-	consAdd.Flag("config", "JSON file to read configuration from").ExistingFileVar(&c.inputFile)
+	consAdd.Flag("config", "JSON or YAML file to read configuration from").ExistingFileVar(&c.inputFile)
 	consAdd.Flag("validate", "Only validates the configuration against the official Schema").BoolVar(&c.validateOnly)
 	consAdd.Flag("output", "Save configuration instead of creating").PlaceHolder("FILE").StringVar(&c.outFile)
 	addCreateFlags(consAdd)
@@ -101,6 +110,7 @@ func configureConsumerCommand(app *kingpin.Application) {
 	consInfo.Arg("stream", "Stream name").StringVar(&c.stream)
 	consInfo.Arg("consumer", "Consumer name").StringVar(&c.consumer)
 	consInfo.Flag("json", "Produce JSON output").Short('j').BoolVar(&c.json)
+	consInfo.Flag("config-out", "Saves the Consumer configuration to a file that can be used with --config").PlaceHolder("FILE").StringVar(&c.outFile)
 
 	consLs := cons.Command("ls", "List known Consumers").Alias("list").Action(c.lsAction)
 	consLs.Arg("stream", "Stream name").StringVar(&c.stream)
@@ -111,11 +121,22 @@ func configureConsumerCommand(app *kingpin.Application) {
 	consNext.Arg("consumer", "Consumer name").Required().StringVar(&c.consumer)
 	consNext.Flag("ack", "Acknowledge received message").Default("true").BoolVar(&c.ack)
 	consNext.Flag("raw", "Show only the message").Short('r').BoolVar(&c.raw)
+	consNext.Flag("pretty", "Indents message bodies that are valid JSON").BoolVar(&c.pretty)
+	consNext.Flag("translate", "Shell command that receives the body on STDIN, its output is shown instead").PlaceHolder("COMMAND").StringVar(&c.translate)
 
 	consRm := cons.Command("rm", "Removes a Consumer").Alias("delete").Alias("del").Action(c.rmAction)
 	consRm.Arg("stream", "Stream name").StringVar(&c.stream)
 	consRm.Arg("consumer", "Consumer name").StringVar(&c.consumer)
 	consRm.Flag("force", "Force removal without prompting").Short('f').BoolVar(&c.force)
+	consRm.Flag("all", "Removes all Consumers on the Stream, same as using '*' as Consumer name").BoolVar(&c.rmAll)
+
+	consGraph := cons.Command("graph", "View a graph of Consumer activity").Action(c.graphAction)
+	consGraph.Arg("stream", "Stream name").StringVar(&c.stream)
+	consGraph.Arg("consumer", "Consumer name").StringVar(&c.consumer)
+
+	consReport := cons.Command("report", "Reports on Consumer health, flagging those where Ack Pending grows").Action(c.reportAction)
+	consReport.Arg("stream", "Stream name").StringVar(&c.stream)
+	consReport.Flag("watch", "Refresh the report continuously").BoolVar(&c.watch)
 
 	consSub := cons.Command("sub", "Retrieves messages from Consumers").Action(c.subAction)
 	consSub.Arg("stream", "Stream name").StringVar(&c.stream)
@@ -125,6 +146,10 @@ func configureConsumerCommand(app *kingpin.Application) {
 }
 
 func (c *consumerCmd) rmAction(_ *kingpin.ParseContext) error {
+	if c.rmAll || c.consumer == "*" {
+		return c.rmAllAction()
+	}
+
 	c.connectAndSetup(true, true)
 
 	if !c.force {
@@ -142,6 +167,210 @@ func (c *consumerCmd) rmAction(_ *kingpin.ParseContext) error {
 	return consumer.Delete()
 }
 
+func (c *consumerCmd) graphAction(_ *kingpin.ParseContext) error {
+	if !terminal.IsTerminal(int(os.Stdout.Fd())) {
+		return fmt.Errorf("graphs can only be shown on a terminal")
+	}
+
+	c.connectAndSetup(true, true)
+
+	consumer, err := c.mgr.LoadConsumer(c.stream, c.consumer)
+	kingpin.FatalIfError(err, "could not load Consumer")
+
+	const points = 60
+	var ackPending, redelivered, delivered []float64
+	var lastDelivered uint64
+
+	add := func(series []float64, v float64) []float64 {
+		series = append(series, v)
+		if len(series) > points {
+			series = series[len(series)-points:]
+		}
+		return series
+	}
+
+	plot := func(series []float64, caption string) string {
+		return asciigraph.Plot(series, asciigraph.Height(8), asciigraph.Width(points), asciigraph.Offset(7), asciigraph.Caption(caption))
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		state, err := consumer.State()
+		if err != nil {
+			return err
+		}
+
+		if lastDelivered > 0 {
+			delivered = add(delivered, float64(state.Delivered.Consumer-lastDelivered))
+		}
+		lastDelivered = state.Delivered.Consumer
+
+		ackPending = add(ackPending, float64(state.NumAckPending))
+		redelivered = add(redelivered, float64(state.NumRedelivered))
+
+		fmt.Print("\033[2J\033[H")
+		fmt.Printf("Consumer %s > %s @ %s\n\n", c.stream, c.consumer, time.Now().Format(time.RFC3339))
+		fmt.Println(plot(ackPending, "Ack Pending"))
+		fmt.Println()
+		fmt.Println(plot(redelivered, "Redelivered"))
+		if len(delivered) > 0 {
+			fmt.Println()
+			fmt.Println(plot(delivered, "Delivered per second"))
+		}
+
+		<-ticker.C
+	}
+}
+
+func (c *consumerCmd) reportAction(_ *kingpin.ParseContext) error {
+	c.connectAndSetup(true, false)
+
+	if c.watch && !terminal.IsTerminal(int(os.Stdout.Fd())) {
+		return fmt.Errorf("watching reports can only be done on a terminal")
+	}
+
+	states := func() (map[string]api.ConsumerInfo, error) {
+		consumers, err := c.mgr.Consumers(c.stream)
+		if err != nil {
+			return nil, err
+		}
+
+		res := make(map[string]api.ConsumerInfo)
+		for _, consumer := range consumers {
+			state, err := consumer.State()
+			if err != nil {
+				return nil, err
+			}
+			res[consumer.Name()] = state
+		}
+
+		return res, nil
+	}
+
+	// ack pending is sampled twice so consumers that can not keep up can be identified
+	previous, err := states()
+	kingpin.FatalIfError(err, "could not load Consumers")
+
+	if !c.watch {
+		fmt.Printf("Sampling %d Consumers on Stream %s for growing Ack Pending\n\n", len(previous), c.stream)
+	}
+
+	for {
+		time.Sleep(time.Second)
+
+		current, err := states()
+		kingpin.FatalIfError(err, "could not load Consumers")
+
+		if c.watch {
+			fmt.Print("\033[2J\033[H")
+		}
+
+		c.renderReport(previous, current)
+
+		if !c.watch {
+			return nil
+		}
+
+		previous = current
+	}
+}
+
+func (c *consumerCmd) renderReport(previous map[string]api.ConsumerInfo, current map[string]api.ConsumerInfo) {
+	var names []string
+	for name := range current {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	table := tablewriter.CreateTable()
+	table.AddTitle(fmt.Sprintf("Consumer report for %s @ %s", c.stream, time.Now().Format("15:04:05")))
+	table.AddHeaders("Consumer", "Mode", "Ack Pending", "Redelivered", "Unprocessed", "Ack Floor", "Trend")
+
+	growing := 0
+	for _, name := range names {
+		state := current[name]
+
+		mode := "Push"
+		if state.Config.DeliverSubject == "" {
+			mode = "Pull"
+		}
+
+		trend := ""
+		if prev, ok := previous[name]; ok && state.NumAckPending > prev.NumAckPending {
+			trend = fmt.Sprintf("growing (+%d)", state.NumAckPending-prev.NumAckPending)
+			growing++
+		}
+
+		table.AddRow(name, mode, humanize.Comma(int64(state.NumAckPending)), humanize.Comma(int64(state.NumRedelivered)), humanize.Comma(int64(state.NumPending)), state.AckFloor.Stream, trend)
+	}
+
+	fmt.Print(table.Render())
+	fmt.Println()
+
+	if growing > 0 {
+		fmt.Printf("%d Consumer(s) have growing Ack Pending and might not be keeping up\n", growing)
+	}
+}
+
+func (c *consumerCmd) rmAllAction() error {
+	c.connectAndSetup(true, false)
+
+	consumers, err := c.mgr.ConsumerNames(c.stream)
+	kingpin.FatalIfError(err, "could not load Consumers")
+
+	if len(consumers) == 0 {
+		fmt.Printf("No Consumers defined for Stream %s\n", c.stream)
+		return nil
+	}
+
+	if !c.force {
+		fmt.Printf("Consumers for Stream %s:\n\n", c.stream)
+		for _, name := range consumers {
+			fmt.Printf("\t%s\n", name)
+		}
+		fmt.Println()
+
+		ok, err := askConfirmation(fmt.Sprintf("Really delete all %d Consumers on Stream %s", len(consumers), c.stream), false)
+		kingpin.FatalIfError(err, "could not obtain confirmation")
+
+		if !ok {
+			return nil
+		}
+	}
+
+	// ephemeral consumers can go away between listing and removing them
+	notFound := func(err error) bool {
+		apiErr, ok := err.(api.ApiError)
+		return ok && apiErr.NotFoundError()
+	}
+
+	failed := 0
+	for _, name := range consumers {
+		consumer, err := c.mgr.LoadConsumer(c.stream, name)
+		if err == nil {
+			err = consumer.Delete()
+		}
+
+		switch {
+		case err == nil:
+			fmt.Printf("Removed Consumer %s > %s\n", c.stream, name)
+		case notFound(err):
+			fmt.Printf("Consumer %s > %s was already removed\n", c.stream, name)
+		default:
+			fmt.Printf("Could not remove Consumer %s > %s: %s\n", c.stream, name, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d Consumers could not be removed", failed)
+	}
+
+	return nil
+}
+
 func (c *consumerCmd) lsAction(pc *kingpin.ParseContext) error {
 	c.connectAndSetup(true, false)
 
@@ -260,6 +489,17 @@ func (c *consumerCmd) infoAction(pc *kingpin.ParseContext) error {
 	consumer, err := c.mgr.LoadConsumer(c.stream, c.consumer)
 	kingpin.FatalIfError(err, "could not load Consumer %s > %s", c.stream, c.consumer)
 
+	if c.outFile != "" {
+		j, err := json.MarshalIndent(consumer.Configuration(), "", "  ")
+		kingpin.FatalIfError(err, "could not encode Consumer configuration")
+
+		err = ioutil.WriteFile(c.outFile, j, 0644)
+		kingpin.FatalIfError(err, "could not save Consumer configuration")
+
+		fmt.Printf("Saved configuration for Consumer %s > %s to %s\n", c.stream, c.consumer, c.outFile)
+		return nil
+	}
+
 	c.showConsumer(consumer)
 
 	return nil
@@ -412,23 +652,21 @@ func (c *consumerCmd) prepareConfig() (cfg *api.ConsumerConfig, err error) {
 	cfg = c.defaultConsumer()
 
 	if c.inputFile != "" {
-		f, err := ioutil.ReadFile(c.inputFile)
+		cfg = &api.ConsumerConfig{}
+		err = loadConfigFile(c.inputFile, cfg.SchemaType(), cfg)
 		if err != nil {
 			return nil, err
 		}
 
-		cfg = &api.ConsumerConfig{}
-		err = json.Unmarshal(f, cfg)
-
 		if cfg.Durable != "" && c.consumer != "" && cfg.Durable != c.consumer {
 			return cfg, fmt.Errorf("non durable consumer name in %s does not match CLI consumer name %s", c.inputFile, c.consumer)
 		}
 
-		return cfg, err
+		return cfg, nil
 	}
 
 	if c.consumer == "" && !c.ephemeral {
-		err = survey.AskOne(&survey.Input{
+		err = askOne(&survey.Input{
 			Message: "Consumer name",
 			Help:    "This will be used for the name of the durable subscription to be used when referencing this Consumer later. Settable using 'name' CLI argument",
 		}, &c.consumer, survey.WithValidator(survey.Required))
@@ -441,7 +679,7 @@ func (c *consumerCmd) prepareConfig() (cfg *api.ConsumerConfig, err error) {
 	}
 
 	if !c.pull && c.delivery == "" {
-		err = survey.AskOne(&survey.Input{
+		err = askOne(&survey.Input{
 			Message: "Delivery target",
 			Help:    "Consumers can be in 'push' or 'pull' mode, in 'push' mode messages are dispatched in real time to a target NATS subject, this is that subject. Leaving this blank creates a 'pull' mode Consumer. Settable using --target and --pull",
 		}, &c.delivery)
@@ -460,7 +698,7 @@ func (c *consumerCmd) prepareConfig() (cfg *api.ConsumerConfig, err error) {
 	}
 
 	if c.startPolicy == "" {
-		err = survey.AskOne(&survey.Input{
+		err = askOne(&survey.Input{
 			Message: "Start policy (all, new, last, 1h, msg sequence)",
 			Help:    "This controls how the Consumer starts out, does it make all messages available, only the latest, ones after a certain time or time sequence. Settable using --deliver",
 		}, &c.startPolicy, survey.WithValidator(survey.Required))
@@ -470,7 +708,7 @@ func (c *consumerCmd) prepareConfig() (cfg *api.ConsumerConfig, err error) {
 	c.setStartPolicy(cfg, c.startPolicy)
 
 	if c.ackPolicy == "" {
-		err = survey.AskOne(&survey.Select{
+		err = askOne(&survey.Select{
 			Message: "Acknowledgement policy",
 			Options: []string{"none", "all", "explicit"},
 			Default: "none",
@@ -480,7 +718,7 @@ func (c *consumerCmd) prepareConfig() (cfg *api.ConsumerConfig, err error) {
 	}
 
 	if c.replayPolicy == "" {
-		err = survey.AskOne(&survey.Select{
+		err = askOne(&survey.Select{
 			Message: "Replay policy",
 			Options: []string{"instant", "original"},
 			Default: "instant",
@@ -509,7 +747,7 @@ func (c *consumerCmd) prepareConfig() (cfg *api.ConsumerConfig, err error) {
 	if cfg.DeliverSubject != "" {
 		if c.replayPolicy == "" {
 			mode := ""
-			err = survey.AskOne(&survey.Select{
+			err = askOne(&survey.Select{
 				Message: "Replay policy",
 				Options: []string{"instant", "original"},
 				Default: "instant",
@@ -525,7 +763,7 @@ func (c *consumerCmd) prepareConfig() (cfg *api.ConsumerConfig, err error) {
 	}
 
 	if c.filterSubject == "_unset_" {
-		err = survey.AskOne(&survey.Input{
+		err = askOne(&survey.Input{
 			Message: "Filter Stream by subject (blank for all)",
 			Default: "",
 			Help:    "Stream can consume more than one subject - or a wildcard - this allows you to filter out just a single subject from all the ones entering the Stream for delivery to the Consumer. Settable using --filter",
@@ -535,7 +773,7 @@ func (c *consumerCmd) prepareConfig() (cfg *api.ConsumerConfig, err error) {
 	cfg.FilterSubject = c.filterSubject
 
 	if c.maxDeliver == 0 && cfg.AckPolicy != api.AckNone {
-		err = survey.AskOne(&survey.Input{
+		err = askOne(&survey.Input{
 			Message: "Maximum Allowed Deliveries",
 			Default: "-1",
 			Help:    "When this is -1 unlimited attempts to deliver an un acknowledged message is made, when this is >0 it will be maximum amount of times a message is delivered after which it is ignored. Settable using --max-deliver.",
@@ -544,7 +782,7 @@ func (c *consumerCmd) prepareConfig() (cfg *api.ConsumerConfig, err error) {
 	}
 
 	if c.maxAckPending == -1 && cfg.AckPolicy != api.AckNone {
-		err = survey.AskOne(&survey.Input{
+		err = askOne(&survey.Input{
 			Message: "Maximum Acknowledgements Pending",
 			Default: "0",
 			Help:    "The maximum number of messages without acknowledgement that can be outstanding, once this limit is reached message delivery will be suspended",
@@ -629,6 +867,15 @@ func (c *consumerCmd) getNextMsgDirect(stream string, consumer string) error {
 	msg, err := c.mgr.NextMsg(stream, consumer)
 	kingpin.FatalIfError(err, "could not load next message")
 
+	body := msg.Data
+	if c.translate != "" {
+		body, err = translateBody(c.translate, body)
+		kingpin.FatalIfError(err, "could not translate message")
+	}
+	if c.pretty {
+		body = prettyJSONBody(body)
+	}
+
 	if !c.raw {
 		info, err := jsm.ParseJSMsgMetadata(msg)
 		if err != nil {
@@ -658,9 +905,9 @@ func (c *consumerCmd) getNextMsgDirect(stream string, consumer string) error {
 		}
 
 		fmt.Println()
-		fmt.Println(string(msg.Data))
+		fmt.Println(string(body))
 	} else {
-		fmt.Println(string(msg.Data))
+		fmt.Println(string(body))
 	}
 
 	if c.ack {
@@ -14,13 +14,19 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/fatih/color"
+	"golang.org/x/crypto/ssh/terminal"
 	"gopkg.in/alecthomas/kingpin.v2"
 
 	"github.com/nats-io/natscli/natscontext"
@@ -40,8 +46,10 @@ func configureCtxCommand(app *kingpin.Application) {
 
 	context := app.Command("context", "Manage nats configuration contexts").Alias("ctx")
 
-	edit := context.Command("edit", "Edit a context in your EDITOR").Alias("vi").Action(c.editCommand)
+	edit := context.Command("edit", "Edit a context using connection flags or in your EDITOR, --timeout 0 removes a saved timeout").Alias("vi").Action(c.editCommand)
 	edit.Arg("name", "The context name to edit").Required().StringVar(&c.name)
+	edit.Flag("description", "Set a friendly description for this context").StringVar(&c.description)
+	edit.Flag("nsc", "URL to a nsc user, eg. nsc://<operator>/<account/user").StringVar(&c.nsc)
 
 	context.Command("ls", "List known contexts").Alias("list").Alias("l").Action(c.listCommand)
 
@@ -78,22 +86,57 @@ func (c *ctxCommand) overrideVars() []string {
 	return list
 }
 
+// editFlags are the flags that, when given on the command line, edit a context without invoking EDITOR
+var editFlags = []string{"server", "user", "password", "creds", "nkey", "tlscert", "tlskey", "tlsca", "timeout", "description", "nsc"}
+
+func (c *ctxCommand) editFlagsGiven(pc *kingpin.ParseContext) bool {
+	for _, name := range editFlags {
+		if flagGiven(pc, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (c *ctxCommand) editCommand(pc *kingpin.ParseContext) error {
+	if !natscontext.IsKnown(c.name) {
+		return fmt.Errorf("unknown context %q", c.name)
+	}
+
+	if c.editFlagsGiven(pc) {
+		return c.editWithFlags(pc)
+	}
+
 	editor := os.Getenv("EDITOR")
 	if editor == "" {
 		return fmt.Errorf("set EDITOR environment variable to your chosen editor")
 	}
 
-	if !natscontext.IsKnown(c.name) {
-		return fmt.Errorf("unknown context %q", c.name)
+	path, err := natscontext.ContextPath(c.name)
+	if err != nil {
+		return err
 	}
 
-	path, err := natscontext.ContextPath(c.name)
+	orig, err := ioutil.ReadFile(path)
 	if err != nil {
 		return err
 	}
 
-	cmd := exec.Command(editor, path)
+	// edits are made to a copy so that invalid JSON does not replace a working context
+	tf, err := ioutil.TempFile("", "nats-context-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tf.Name())
+
+	_, err = tf.Write(orig)
+	tf.Close()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(editor, tf.Name())
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -103,6 +146,57 @@ func (c *ctxCommand) editCommand(pc *kingpin.ParseContext) error {
 		return err
 	}
 
+	edited, err := ioutil.ReadFile(tf.Name())
+	if err != nil {
+		return err
+	}
+
+	settings := map[string]interface{}{}
+	err = json.Unmarshal(edited, &settings)
+	if err != nil {
+		return fmt.Errorf("context %q was not saved, invalid JSON: %s", c.name, err)
+	}
+
+	err = ioutil.WriteFile(path, edited, 0600)
+	if err != nil {
+		return err
+	}
+
+	return c.showCommand(pc)
+}
+
+// editWithFlags changes only the settings given on the command line, values set using environment variables are not saved
+func (c *ctxCommand) editWithFlags(pc *kingpin.ParseContext) error {
+	var opts []natscontext.Option
+
+	for flag, opt := range map[string]natscontext.Option{
+		"server":      natscontext.WithServerURL(servers),
+		"user":        natscontext.WithUser(username),
+		"password":    natscontext.WithPassword(password),
+		"creds":       natscontext.WithCreds(creds),
+		"nkey":        natscontext.WithNKey(nkey),
+		"tlscert":     natscontext.WithCertificate(tlsCert),
+		"tlskey":      natscontext.WithKey(tlsKey),
+		"tlsca":       natscontext.WithCA(tlsCA),
+		"description": natscontext.WithDescription(c.description),
+		"nsc":         natscontext.WithNscUrl(c.nsc),
+		"timeout":     natscontext.WithTimeout(timeout),
+	} {
+		if flagGiven(pc, flag) {
+			opts = append(opts, opt)
+		}
+	}
+
+	config, err := natscontext.New(c.name, true, opts...)
+	if err != nil {
+		return err
+	}
+
+	err = config.Save(c.name)
+	if err != nil {
+		return err
+	}
+
 	return c.showCommand(pc)
 }
 
@@ -164,6 +258,9 @@ func (c *ctxCommand) showCommand(_ *kingpin.ParseContext) error {
 	c.showIfNotEmpty("          Key: %s\n", cfg.Key())
 	c.showIfNotEmpty("           CA: %s\n", cfg.CA())
 	c.showIfNotEmpty("   NSC Lookup: %s\n", cfg.NscURL())
+	if cfg.Timeout() > 0 {
+		c.showIfNotEmpty("      Timeout: %s\n", cfg.Timeout().String())
+	}
 	c.showIfNotEmpty("         Path: %s\n", cfg.Path())
 
 	fmt.Println()
@@ -188,7 +285,7 @@ func (c *ctxCommand) createCommand(pc *kingpin.ParseContext) error {
 		load = true
 	}
 
-	config, err := natscontext.New(lname, load,
+	opts := []natscontext.Option{
 		natscontext.WithServerURL(servers),
 		natscontext.WithUser(username),
 		natscontext.WithPassword(password),
@@ -199,7 +296,13 @@ func (c *ctxCommand) createCommand(pc *kingpin.ParseContext) error {
 		natscontext.WithCA(tlsCA),
 		natscontext.WithDescription(c.description),
 		natscontext.WithNscUrl(c.nsc),
-	)
+	}
+
+	if flagGiven(pc, "timeout") {
+		opts = append(opts, natscontext.WithTimeout(timeout))
+	}
+
+	config, err := natscontext.New(lname, load, opts...)
 	if err != nil {
 		return err
 	}
@@ -238,14 +341,26 @@ func (c *ctxCommand) selectCommand(pc *kingpin.ParseContext) error {
 		return fmt.Errorf("no context defined")
 	}
 
-	if c.name == "" {
-		err := survey.AskOne(&survey.Select{
-			Message: "Select a Context",
-			Options: known,
+	switch {
+	case c.name != "":
+	case noPrompt:
+		return fmt.Errorf("a context name is required when prompts are disabled")
+	case terminal.IsTerminal(int(os.Stdin.Fd())):
+		// typing filters the list of contexts
+		err := askOne(&survey.Select{
+			Message:  "Select a Context",
+			Options:  known,
+			PageSize: 15,
 		}, &c.name)
 		if err != nil {
 			return err
 		}
+	default:
+		name, err := c.selectFromNumberedList(known)
+		if err != nil {
+			return err
+		}
+		c.name = name
 	}
 
 	if c.name == "" {
@@ -260,6 +375,31 @@ func (c *ctxCommand) selectCommand(pc *kingpin.ParseContext) error {
 	return c.showCommand(pc)
 }
 
+// selectFromNumberedList reads a choice from STDIN by number or name for use when not on a terminal
+func (c *ctxCommand) selectFromNumberedList(known []string) (string, error) {
+	for i, name := range known {
+		fmt.Printf("%3d) %s\n", i+1, name)
+	}
+	fmt.Print("Select a Context: ")
+
+	choice, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	choice = strings.TrimSpace(choice)
+	fmt.Println()
+
+	if i, err := strconv.Atoi(choice); err == nil {
+		if i < 1 || i > len(known) {
+			return "", fmt.Errorf("invalid selection %d", i)
+		}
+
+		return known[i-1], nil
+	}
+
+	return choice, nil
+}
+
 func (c *ctxCommand) showIfNotEmpty(format string, arg ...string) {
 	if len(arg) == 0 || arg[0] == "" {
 		return
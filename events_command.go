@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/nats-io/jsm.go/api"
 	"github.com/nats-io/nats.go"
@@ -42,8 +43,8 @@ func configureEventsCommand(app *kingpin.Application) {
 	events.Flag("filter", "Filter across the entire event using regular expressions").Default(".").StringVar(&c.bodyF)
 	events.Flag("js-metric", "Shows JetStream metric events (false)").Default("false").BoolVar(&c.showJsMetrics)
 	events.Flag("js-advisory", "Shows advisory events (false)").Default("false").BoolVar(&c.showJsAdvisories)
-	events.Flag("srv-advisory", "Shows NATS Server advisories (true)").Default("true").BoolVar(&c.showServerAdvisories)
-	events.Flag("subjects", "Show Advisories and Metrics received on specific subjects").PlaceHolder("SUBJECTS").StringsVar(&c.extraSubjects)
+	events.Flag("srv-advisory", "Shows NATS Server advisories (true unless other events are chosen)").Default("true").BoolVar(&c.showServerAdvisories)
+	events.Flag("subjects", "Show Advisories, Metrics and other events received on specific subjects, wildcards are supported").PlaceHolder("SUBJECTS").StringsVar(&c.extraSubjects)
 }
 
 func (c *eventsCmd) handleNATSEvent(m *nats.Msg) {
@@ -66,13 +67,10 @@ func (c *eventsCmd) handleNATSEvent(m *nats.Msg) {
 			log.Printf("Received %s event on subject %s", kind, m.Subject)
 		}
 
-		if kind == "io.nats.unknown_message" {
-			return fmt.Errorf("unknown event schema")
-		}
-
 		ne, ok := event.(api.Event)
-		if !ok {
-			return fmt.Errorf("event %q does not implement the Event interface", kind)
+		if kind == "io.nats.unknown_message" || !ok {
+			c.renderUnknownEvent(m)
+			return nil
 		}
 
 		var format api.RenderFormat
@@ -102,17 +100,39 @@ func (c *eventsCmd) handleNATSEvent(m *nats.Msg) {
 	}
 }
 
+// renderUnknownEvent shows events without a known schema as JSON so custom subjects remain readable
+func (c *eventsCmd) renderUnknownEvent(m *nats.Msg) {
+	if c.ce {
+		fmt.Println(string(m.Data))
+		return
+	}
+
+	if c.short {
+		fmt.Printf("[%s] [%s] %s\n", time.Now().Format("15:04:05"), m.Subject, m.Data)
+		return
+	}
+
+	fmt.Printf("[%s] [%s] Unknown Event\n\n", time.Now().Format("15:04:05"), m.Subject)
+	fmt.Println(leftPad(string(prettyJSONBody(m.Data)), 10))
+	fmt.Println()
+}
+
 func (c *eventsCmd) Printf(f string, arg ...interface{}) {
 	if !c.json {
 		fmt.Printf(f, arg...)
 	}
 }
 
-func (c *eventsCmd) eventsAction(_ *kingpin.ParseContext) error {
+func (c *eventsCmd) eventsAction(pc *kingpin.ParseContext) error {
 	if c.ce {
 		c.json = true
 	}
 
+	// server advisories are shown by default only, choosing other events shows just those
+	if !flagGiven(pc, "srv-advisory") && (c.showJsAdvisories || c.showJsMetrics || len(c.extraSubjects) > 0) {
+		c.showServerAdvisories = false
+	}
+
 	nc, _, err := prepareHelper("", natsOpts()...)
 	kingpin.FatalIfError(err, "setup failed")
 
@@ -156,7 +176,7 @@ func (c *eventsCmd) eventsAction(_ *kingpin.ParseContext) error {
 
 	if len(c.extraSubjects) > 0 {
 		for _, s := range c.extraSubjects {
-			c.Printf("Listening for latency samples on %s\n", s)
+			c.Printf("Listening for events on %s\n", s)
 			nc.Subscribe(s, func(m *nats.Msg) {
 				c.handleNATSEvent(m)
 			})
@@ -20,12 +20,14 @@ require (
 	github.com/nats-io/jsm.go v0.0.20-0.20201127115233-95ad014f7ee9
 	github.com/nats-io/nats-server/v2 v2.1.8-0.20201126001621-0e8e85c52f8b
 	github.com/nats-io/nats.go v1.10.1-0.20201111151633-9e1f4a0d80d8
+	github.com/nats-io/nkeys v0.2.0
 	github.com/tylertreat/hdrhistogram-writer v0.0.0-20180430173243-73b8d31ba571
 	github.com/xeipuuv/gojsonpointer v0.0.0-20190905194746-02993c407bfb // indirect
 	github.com/xeipuuv/gojsonschema v1.2.0
 	github.com/xlab/tablewriter v0.0.0-20160610135559-80b567a11ad5
 	golang.org/x/crypto v0.0.0-20201016220609-9e8e0b390897
 	golang.org/x/text v0.3.2 // indirect
+	google.golang.org/protobuf v1.24.0
 	gopkg.in/alecthomas/kingpin.v2 v2.2.6
 	gopkg.in/yaml.v2 v2.3.0 // indirect
 )
@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/nats-io/jsm.go/api"
 	"github.com/xeipuuv/gojsonschema"
@@ -12,7 +14,7 @@ type SchemaValidator struct{}
 func (v SchemaValidator) ValidateStruct(data interface{}, schemaType string) (ok bool, errs []string) {
 	s, err := api.Schema(schemaType)
 	if err != nil {
-		return false, []string{"unknown schema type %s", schemaType}
+		return false, []string{fmt.Sprintf("unknown schema type %s", schemaType)}
 	}
 
 	ls := gojsonschema.NewBytesLoader(s)
@@ -28,8 +30,22 @@ func (v SchemaValidator) ValidateStruct(data interface{}, schemaType string) (ok
 
 	errors := make([]string, len(result.Errors()))
 	for i, verr := range result.Errors() {
-		errors[i] = verr.String()
+		errors[i] = fmt.Sprintf("%s: %s", schemaErrorPath(verr), verr.Description())
 	}
 
 	return false, errors
 }
+
+// schemaErrorPath is the JSON path to the value that failed validation, $ being the document root
+func schemaErrorPath(verr gojsonschema.ResultError) string {
+	path := "$"
+	for _, p := range strings.Split(verr.Context().String(), ".")[1:] {
+		if _, err := strconv.Atoi(p); err == nil {
+			path += "[" + p + "]"
+		} else {
+			path += "." + p
+		}
+	}
+
+	return path
+}
@@ -213,3 +213,18 @@ func TestConsumerConfiguration(t *testing.T) {
 	cfg.ReplayPolicy = api.ReplayOriginal
 	validateExpectSuccess(t, cfg)
 }
+
+func TestSchemaValidatorErrorPaths(t *testing.T) {
+	ok, errs := new(SchemaValidator).ValidateStruct(map[string]interface{}{"subjects": []string{""}}, "io.nats.jetstream.api.v1.stream_configuration")
+	if ok {
+		t.Fatalf("expected validation to fail")
+	}
+
+	for _, err := range errs {
+		if err == "$.subjects[0]: String length must be greater than or equal to 1" {
+			return
+		}
+	}
+
+	t.Fatalf("did not find error for the empty subject in %v", errs)
+}
@@ -27,20 +27,37 @@ type latencyCmd struct {
 	testDuration  time.Duration
 	histFile      string
 	numPubs       int
+
+	reqSubject  string
+	reqSamples  int
+	reqWarmup   int
+	reqInterval time.Duration
 }
 
 func configureLatencyCommand(app *kingpin.Application) {
 	c := &latencyCmd{}
 
-	latency := app.Command("latency", "Perform latency tests between two NATS servers").Alias("lat").Action(c.latencyAction)
-	latency.Flag("server-b", "The second server to to subscribe on").Required().StringVar(&c.serverB)
+	latency := app.Command("latency", "Perform latency tests between two NATS servers or against a request-reply service").Alias("lat").Action(c.latencyAction)
+	latency.Flag("server-b", "The second server to to subscribe on").StringVar(&c.serverB)
 	latency.Flag("size", "Message size").Default("8").IntVar(&c.msgSize)
 	latency.Flag("rate", "Rate of messages per second").Default("1000").IntVar(&c.targetPubRate)
 	latency.Flag("duration", "Test duration").Default("5s").DurationVar(&c.testDuration)
 	latency.Flag("histogram", "Output file to store the histogram in").StringVar(&c.histFile)
+	latency.Flag("request", "Measures request-reply latency against a service listening on this subject instead").PlaceHolder("SUBJECT").StringVar(&c.reqSubject)
+	latency.Flag("samples", "When using --request, how many requests to measure").Default("100").IntVar(&c.reqSamples)
+	latency.Flag("warmup", "When using --request, how many requests to send before measuring").Default("10").IntVar(&c.reqWarmup)
+	latency.Flag("interval", "When using --request, how long to wait between requests").Default("0s").DurationVar(&c.reqInterval)
 }
 
 func (c *latencyCmd) latencyAction(_ *kingpin.ParseContext) error {
+	if c.reqSubject != "" {
+		return c.requestLatency()
+	}
+
+	if c.serverB == "" {
+		return fmt.Errorf("--server-b is required unless measuring request latency using --request")
+	}
+
 	start := time.Now()
 	c.numPubs = int(c.testDuration/time.Second) * c.targetPubRate
 	log.SetFlags(0)
@@ -192,6 +209,112 @@ func (c *latencyCmd) latencyAction(_ *kingpin.ParseContext) error {
 	return nil
 }
 
+// requestLatency measures individual request-reply round trips one at a time
+func (c *latencyCmd) requestLatency() error {
+	if c.reqSamples < 1 {
+		return fmt.Errorf("at least 1 sample is required")
+	}
+
+	nc, err := newNatsConn("", natsOpts()...)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	data := make([]byte, c.msgSize)
+	io.ReadFull(rand.Reader, data)
+
+	log.SetFlags(0)
+	log.Printf("Measuring %d requests to %s after %d warmup requests\n", c.reqSamples, c.reqSubject, c.reqWarmup)
+
+	for i := 0; i < c.reqWarmup; i++ {
+		_, err = nc.Request(c.reqSubject, data, timeout)
+		if err != nil {
+			return fmt.Errorf("warmup request failed: %s", err)
+		}
+	}
+
+	durations := make([]time.Duration, 0, c.reqSamples)
+	for i := 0; i < c.reqSamples; i++ {
+		if i > 0 && c.reqInterval > 0 {
+			time.Sleep(c.reqInterval)
+		}
+
+		start := time.Now()
+		_, err = nc.Request(c.reqSubject, data, timeout)
+		if err != nil {
+			return fmt.Errorf("request %d failed: %s", i+1, err)
+		}
+		durations = append(durations, time.Since(start))
+	}
+
+	if c.histFile != "" {
+		if err := c.writeRawFile(c.histFile+".raw", durations); err != nil {
+			log.Printf("Unable to write raw output file: %v", err)
+		}
+	}
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	avg := total / time.Duration(len(durations))
+
+	var variance float64
+	for _, d := range durations {
+		variance += math.Pow(float64(d-avg), 2)
+	}
+	stddev := time.Duration(math.Sqrt(variance / float64(len(durations))))
+
+	med, err := c.getMedian(durations)
+	if err != nil {
+		return err
+	}
+
+	log.Println("==============================")
+	log.Printf("Minimum Latency: %v", c.fmtDur(durations[0]))
+	log.Printf("Average Latency: %v", c.fmtDur(avg))
+	log.Printf("Median Latency : %v", c.fmtDur(med))
+	log.Printf("Maximum Latency: %v", c.fmtDur(durations[len(durations)-1]))
+	log.Printf("Std Deviation  : %v", c.fmtDur(stddev))
+	log.Println("==============================")
+	log.Println()
+
+	c.printHistogram(durations, 10)
+
+	return nil
+}
+
+// printHistogram shows sorted durations as a bar per equally sized bucket
+func (c *latencyCmd) printHistogram(durations []time.Duration, buckets int) {
+	min := durations[0]
+	max := durations[len(durations)-1]
+	width := (max - min) / time.Duration(buckets)
+	if width == 0 {
+		width = 1
+	}
+
+	counts := make([]int, buckets)
+	most := 0
+	for _, d := range durations {
+		b := int((d - min) / width)
+		if b >= buckets {
+			b = buckets - 1
+		}
+		counts[b]++
+		if counts[b] > most {
+			most = counts[b]
+		}
+	}
+
+	for i, cnt := range counts {
+		bar := strings.Repeat("#", int(math.Ceil(float64(cnt)/float64(most)*40)))
+		log.Printf("%12v | %-40s %d", c.fmtDur(min+time.Duration(i)*width), bar, cnt)
+	}
+}
+
 // Just pretty print the byte sizes.
 func (c *latencyCmd) byteSize(n int) string {
 	sizes := []string{"B", "K", "M", "G", "T"}
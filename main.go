@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"math/rand"
 	"os"
 	"time"
 
@@ -22,14 +23,17 @@ var (
 	username string
 	password string
 	nkey     string
+	userJWT  string
+	nkeySeed string
 	cfgCtx   string
 	ctxError error
 	trace    bool
+	noPrompt bool
 
 	// used during tests
 	skipContexts bool
 
-	overrideEnvVars = []string{"NATS_URL", "NATS_USER", "NATS_PASSWORD", "NATS_CREDS", "NATS_NKEY", "NATS_CERT", "NATS_KEY", "NATS_CA", "NATS_TIMEOUT"}
+	overrideEnvVars = []string{"NATS_URL", "NATS_USER", "NATS_PASSWORD", "NATS_CREDS", "NATS_NKEY", "NATS_JWT", "NATS_NKEY_SEED", "NATS_CERT", "NATS_KEY", "NATS_CA", "NATS_TIMEOUT"}
 )
 
 func main() {
@@ -37,6 +41,9 @@ func main() {
 		version = "development"
 	}
 
+	// template Random values and reply sleeps should differ between runs
+	rand.Seed(time.Now().UnixNano())
+
 	ncli := kingpin.New("nats", "NATS Management Utility")
 	ncli.Author("NATS Authors <info@nats.io>")
 	ncli.Version(version)
@@ -47,12 +54,15 @@ func main() {
 	ncli.Flag("password", "Password").Envar("NATS_PASSWORD").PlaceHolder("NATS_PASSWORD").StringVar(&password)
 	ncli.Flag("creds", "User credentials").Envar("NATS_CREDS").PlaceHolder("NATS_CREDS").StringVar(&creds)
 	ncli.Flag("nkey", "User NKEY").Envar("NATS_NKEY").PlaceHolder("NATS_NKEY").StringVar(&nkey)
+	ncli.Flag("jwt", "User JWT, insecure as it will be stored in the shell history").Envar("NATS_JWT").PlaceHolder("NATS_JWT").StringVar(&userJWT)
+	ncli.Flag("nkey-seed", "User NKEY seed, insecure as it will be stored in the shell history").Envar("NATS_NKEY_SEED").PlaceHolder("NATS_NKEY_SEED").StringVar(&nkeySeed)
 	ncli.Flag("tlscert", "TLS public certificate").Envar("NATS_CERT").PlaceHolder("NATS_CERT").ExistingFileVar(&tlsCert)
 	ncli.Flag("tlskey", "TLS private key").Envar("NATS_KEY").PlaceHolder("NATS_KEY").ExistingFileVar(&tlsKey)
 	ncli.Flag("tlsca", "TLS certificate authority chain").Envar("NATS_CA").PlaceHolder("NATS_CA").ExistingFileVar(&tlsCA)
 	ncli.Flag("timeout", "Time to wait on responses from NATS").Default("2s").Envar("NATS_TIMEOUT").PlaceHolder("NATS_TIMEOUT").DurationVar(&timeout)
 	ncli.Flag("context", "Configuration context").StringVar(&cfgCtx)
-	ncli.Flag("trace", "Trace API interactions").BoolVar(&trace)
+	ncli.Flag("trace", "Trace API interactions, pub and request also show the NATS protocol").BoolVar(&trace)
+	ncli.Flag("no-prompt", "Disables interactive prompts, destructive actions then require --force").Envar("NATS_NO_PROMPT").BoolVar(&noPrompt)
 
 	ncli.PreAction(prepareConfig)
 
@@ -61,6 +71,7 @@ func main() {
 	configureActCommand(ncli)
 	configureBackupCommand(ncli)
 	configureBenchCommand(ncli)
+	configureConnectionCommand(ncli)
 	configureConsumerCommand(ncli)
 	configureCtxCommand(ncli)
 	configureEventsCommand(ncli)
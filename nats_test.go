@@ -14,6 +14,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -23,6 +24,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -39,17 +41,20 @@ func init() {
 	skipContexts = true
 }
 
+func natsCliCommand(args ...string) string {
+	if os.Getenv("CI") == "true" {
+		return fmt.Sprintf("./nats %s", strings.Join(args, " "))
+	}
+
+	return fmt.Sprintf("go run $(ls *.go | grep -v _test.go) %s", strings.Join(args, " "))
+}
+
 func runNatsCli(t *testing.T, args ...string) (output []byte) {
 	t.Helper()
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	var cmd string
-	if os.Getenv("CI") == "true" {
-		cmd = fmt.Sprintf("./nats %s", strings.Join(args, " "))
-	} else {
-		cmd = fmt.Sprintf("go run $(ls *.go | grep -v _test.go) %s", strings.Join(args, " "))
-	}
+	cmd := natsCliCommand(args...)
 	// t.Logf("Running: %q", cmd)
 
 	execution := exec.CommandContext(ctx, "bash", "-c", cmd)
@@ -368,6 +373,38 @@ func TestCLIStreamBackupAndRestore(t *testing.T) {
 	}
 }
 
+func TestCLIStreamRestoreRemap(t *testing.T) {
+	srv, nc, mgr := setupJStreamTest(t)
+	defer srv.Shutdown()
+
+	stream, err := mgr.NewStreamFromDefault("file1", file1Stream())
+	checkErr(t, err, "could not create stream: %v", err)
+
+	for i := 0; i < 10; i++ {
+		nc.Publish("js.file.1", []byte(RandomString(100)))
+	}
+
+	tf, err := ioutil.TempFile("", "")
+	checkErr(t, err, "temp file failed")
+	tf.Close()
+	os.Remove(tf.Name())
+	defer os.Remove(tf.Name())
+
+	runNatsCli(t, fmt.Sprintf("--server='%s' str backup file1 %s --no-progress", srv.ClientURL(), tf.Name()))
+	stream.Delete()
+
+	runNatsCli(t, fmt.Sprintf("--server='%s' str restore file1 %s --no-progress --remap 'js.file.>:js.restored.>'", srv.ClientURL(), tf.Name()))
+
+	info := streamInfo(t, mgr, "file1")
+	if !cmp.Equal(info.Config.Subjects, []string{"js.restored.>"}) {
+		t.Fatalf("expected remapped subjects got %v", info.Config.Subjects)
+	}
+
+	if info.State.Msgs != 10 {
+		t.Fatalf("expected 10 messages got %d", info.State.Msgs)
+	}
+}
+
 func RandomString(n int) string {
 	var letterRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 
@@ -435,6 +472,24 @@ func TestCLIConsumerDelete(t *testing.T) {
 	}
 }
 
+func TestCLIConsumerDeleteAll(t *testing.T) {
+	srv, _, mgr := setupConsTest(t)
+	defer srv.Shutdown()
+
+	for _, name := range []string{"c1", "c2"} {
+		_, err := mgr.NewConsumer("mem1", jsm.DurableName(name))
+		checkErr(t, err, "could not create consumer: %v", err)
+	}
+
+	runNatsCli(t, fmt.Sprintf("--server='%s' con rm mem1 '*' -f", srv.ClientURL()))
+
+	list, err := mgr.ConsumerNames("mem1")
+	checkErr(t, err, "could not check consumer: %v", err)
+	if len(list) != 0 {
+		t.Fatalf("Expected no consumer, got %v", list)
+	}
+}
+
 func TestCLIConsumerAdd(t *testing.T) {
 	srv, _, mgr := setupConsTest(t)
 	defer srv.Shutdown()
@@ -542,6 +597,100 @@ func TestCLIStreamCopy(t *testing.T) {
 	}
 }
 
+func TestCLIStreamCopyData(t *testing.T) {
+	srv, nc, mgr := setupConsTest(t)
+	defer srv.Shutdown()
+
+	_, err := mgr.NewStreamFromDefault("file1", file1Stream())
+	checkErr(t, err, "could not create stream: %v", err)
+
+	for _, storage := range []string{"mem", "file"} {
+		t.Run(storage, func(t *testing.T) {
+			for i := 1; i <= 3; i++ {
+				_, err := nc.Request(fmt.Sprintf("js.%s.1", storage), []byte(fmt.Sprintf("msg%d", i)), time.Second)
+				checkErr(t, err, "publish failed: %v", err)
+			}
+
+			source, err := mgr.LoadStream(storage + "1")
+			checkErr(t, err, "could not load stream: %v", err)
+			err = source.DeleteMessage(2)
+			checkErr(t, err, "could not delete message: %v", err)
+
+			runNatsCli(t, fmt.Sprintf("--server='%s' str cp %s1 %s2 --subjects js.copy.%s --replicas 1 --data", srv.ClientURL(), storage, storage, storage))
+
+			info := streamInfo(t, mgr, storage+"2")
+			if info.State.Msgs != 2 {
+				t.Fatalf("expected 2 messages in %s2 got %d", storage, info.State.Msgs)
+			}
+		})
+	}
+}
+
+func TestCLIStreamGetTime(t *testing.T) {
+	srv, nc, _ := setupConsTest(t)
+	defer srv.Shutdown()
+
+	_, err := nc.Request("js.mem.1", []byte("msg1"), time.Second)
+	checkErr(t, err, "publish failed: %v", err)
+
+	time.Sleep(1100 * time.Millisecond)
+	ts := time.Now().UTC().Format(time.RFC3339)
+	time.Sleep(1100 * time.Millisecond)
+
+	for i := 2; i <= 3; i++ {
+		_, err := nc.Request("js.mem.1", []byte(fmt.Sprintf("msg%d", i)), time.Second)
+		checkErr(t, err, "publish failed: %v", err)
+	}
+
+	out := runNatsCli(t, fmt.Sprintf("--server='%s' str get mem1 --time %s --json", srv.ClientURL(), ts))
+
+	msg := api.StoredMsg{}
+	err = json.Unmarshal(out, &msg)
+	checkErr(t, err, "could not parse output: %v", err)
+
+	if msg.Sequence != 2 {
+		t.Fatalf("expected sequence 2 got %d", msg.Sequence)
+	}
+
+	if string(msg.Data) != "msg2" {
+		t.Fatalf("expected msg2 got %q", msg.Data)
+	}
+}
+
+func TestCLIStreamGaps(t *testing.T) {
+	srv, nc, mgr := setupConsTest(t)
+	defer srv.Shutdown()
+
+	_, err := mgr.NewStreamFromDefault("file1", file1Stream())
+	checkErr(t, err, "could not create stream: %v", err)
+
+	for _, storage := range []string{"mem", "file"} {
+		t.Run(storage, func(t *testing.T) {
+			for i := 1; i <= 6; i++ {
+				_, err := nc.Request(fmt.Sprintf("js.%s.1", storage), []byte(fmt.Sprintf("msg%d", i)), time.Second)
+				checkErr(t, err, "publish failed: %v", err)
+			}
+
+			stream, err := mgr.LoadStream(storage + "1")
+			checkErr(t, err, "could not load stream: %v", err)
+			for _, seq := range []int{1, 3, 4} {
+				err = stream.DeleteMessage(seq)
+				checkErr(t, err, "could not delete message: %v", err)
+			}
+
+			out := runNatsCli(t, fmt.Sprintf("--server='%s' str gaps %s1 --scan", srv.ClientURL(), storage))
+			if !strings.Contains(string(out), "Present: 2, 5-6") || !strings.Contains(string(out), "Absent: 1, 3-4") {
+				t.Fatalf("unexpected gaps report: %s", out)
+			}
+
+			out = runNatsCli(t, fmt.Sprintf("--server='%s' str gaps %s1", srv.ClientURL(), storage))
+			if !strings.Contains(string(out), "Present: none") || !strings.Contains(string(out), "Unscanned: 2-6") {
+				t.Fatalf("unexpected unscanned gaps report: %s", out)
+			}
+		})
+	}
+}
+
 func TestCLIConsumerCopy(t *testing.T) {
 	srv, _, mgr := setupConsTest(t)
 	defer srv.Shutdown()
@@ -694,3 +843,360 @@ func TestCLIMessageRm(t *testing.T) {
 		t.Fatalf("loading delete message did not fail")
 	}
 }
+
+func TestCLIPubJSAck(t *testing.T) {
+	srv, _, mgr := setupConsTest(t)
+	defer srv.Shutdown()
+
+	out := runNatsCli(t, fmt.Sprintf("--server='%s' pub js.mem.1 hello --js-ack", srv.ClientURL()))
+	if !strings.Contains(string(out), "stored in Stream mem1 sequence 1") {
+		t.Fatalf("expected a stream acknowledgement, got: %s", string(out))
+	}
+
+	info := streamInfo(t, mgr, "mem1")
+	if info.State.Msgs != 1 {
+		t.Fatalf("expected 1 message but got %d", info.State.Msgs)
+	}
+}
+
+func TestCLIRequestMultipleSubjects(t *testing.T) {
+	srv, nc, _ := setupJStreamTest(t)
+	defer srv.Shutdown()
+
+	_, err := nc.Subscribe("service.a", func(m *nats.Msg) { m.Respond([]byte("pong")) })
+	checkErr(t, err, "subscribe failed")
+	nc.Flush()
+
+	out := runNatsCli(t, fmt.Sprintf("--server='%s' request service.a,service.b ping", srv.ClientURL()))
+	if !strings.Contains(string(out), "service.a") || !strings.Contains(string(out), "4 B") {
+		t.Fatalf("expected a reply from service.a, got: %s", string(out))
+	}
+
+	if !strings.Contains(string(out), "service.b") || !strings.Contains(string(out), "no responders") {
+		t.Fatalf("expected a failure for service.b, got: %s", string(out))
+	}
+}
+
+func TestCLIRequestReplies(t *testing.T) {
+	srv, nc, _ := setupJStreamTest(t)
+	defer srv.Shutdown()
+
+	for i := 1; i <= 2; i++ {
+		reply := fmt.Sprintf("instance %d", i)
+		_, err := nc.Subscribe("service", func(m *nats.Msg) { m.Respond([]byte(reply)) })
+		checkErr(t, err, "subscribe failed")
+	}
+	nc.Flush()
+
+	out := runNatsCli(t, fmt.Sprintf("--server='%s' request service ping --replies 3 --raw --timeout 500ms", srv.ClientURL()))
+	if !strings.Contains(string(out), "instance 1\n") || !strings.Contains(string(out), "instance 2\n") {
+		t.Fatalf("expected replies from both instances, got: %s", string(out))
+	}
+}
+
+func TestCLIRequestJSON(t *testing.T) {
+	srv, nc, _ := setupJStreamTest(t)
+	defer srv.Shutdown()
+
+	_, err := nc.Subscribe("service", func(m *nats.Msg) { m.Respond([]byte("pong")) })
+	checkErr(t, err, "subscribe failed")
+	nc.Flush()
+
+	out := runNatsCli(t, fmt.Sprintf("--server='%s' request service ping --json", srv.ClientURL()))
+	reply := reqReply{}
+	err = json.Unmarshal(out, &reply)
+	checkErr(t, err, "invalid JSON output: %s", string(out))
+
+	if string(reply.Data) != "pong" || reply.RTT == 0 || !strings.HasPrefix(reply.Subject, "_INBOX.") {
+		t.Fatalf("invalid reply: %+v", reply)
+	}
+}
+
+func TestCLIRequestOut(t *testing.T) {
+	srv, nc, _ := setupJStreamTest(t)
+	defer srv.Shutdown()
+
+	body := []byte{0, 1, 2, '\n', 255}
+	_, err := nc.Subscribe("service", func(m *nats.Msg) { m.Respond(body) })
+	checkErr(t, err, "subscribe failed")
+	nc.Flush()
+
+	out := runNatsCli(t, fmt.Sprintf("--server='%s' request service ping --binary", srv.ClientURL()))
+	if !bytes.Equal(out, body) {
+		t.Fatalf("expected binary reply %v got %v", body, out)
+	}
+
+	dir, err := ioutil.TempDir("", "")
+	checkErr(t, err, "temp dir failed")
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "reply")
+	runNatsCli(t, fmt.Sprintf("--server='%s' request service ping --out '%s'", srv.ClientURL(), target))
+
+	written, err := ioutil.ReadFile(target)
+	checkErr(t, err, "read failed: %v", err)
+	if !bytes.Equal(written, body) {
+		t.Fatalf("expected binary reply %v got %v", body, written)
+	}
+}
+
+func TestCLIServerMapping(t *testing.T) {
+	out := runNatsCli(t, "server mapping 'orders.*.*' 'orders.$2.$1=80' 'archive.$2.$1=20' --subject orders.new.eu")
+	if !regexp.MustCompile(`orders\.\$2\.\$1\s+\|\s+80%\s+\|\s+orders\.eu\.new`).Match(out) {
+		t.Fatalf("weighted mapping not shown: %s", out)
+	}
+
+	out = runNatsCli(t, "server mapping 'svc.>' 'svc.v2.>=25' --subject svc.ping.eu")
+	if !regexp.MustCompile(`svc\.v2\.>\s+\|\s+25%\s+\|\s+svc\.v2\.ping\.eu`).Match(out) {
+		t.Fatalf("full wildcard mapping not shown: %s", out)
+	}
+	if !regexp.MustCompile(`unmapped\s+\|\s+75%\s+\|\s+svc\.ping\.eu`).Match(out) {
+		t.Fatalf("remaining weight not shown: %s", out)
+	}
+}
+
+func TestCLIPubFromDir(t *testing.T) {
+	srv, nc, _ := setupJStreamTest(t)
+	defer srv.Shutdown()
+
+	dir, err := ioutil.TempDir("", "")
+	checkErr(t, err, "temp dir failed")
+	defer os.RemoveAll(dir)
+
+	for i, body := range []string{"one", "two", "three"} {
+		err = ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("%03d", i)), []byte(body), 0600)
+		checkErr(t, err, "write failed: %v", err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "001.headers"), []byte("X-Test: two\n"), 0600)
+	checkErr(t, err, "write failed: %v", err)
+
+	sub, err := nc.SubscribeSync("test")
+	checkErr(t, err, "subscribe failed")
+	nc.Flush()
+
+	runNatsCli(t, fmt.Sprintf("--server='%s' pub test --from-dir '%s' --rate 100", srv.ClientURL(), dir))
+
+	for _, body := range []string{"one", "two", "three"} {
+		msg, err := sub.NextMsg(time.Second)
+		checkErr(t, err, "did not receive message: %v", err)
+
+		if string(msg.Data) != body {
+			t.Fatalf("expected %q got %q", body, msg.Data)
+		}
+
+		if body == "two" && msg.Header.Get("X-Test") != "two" {
+			t.Fatalf("expected sidecar headers on message two, got %v", msg.Header)
+		}
+
+		if body != "two" && len(msg.Header) > 0 {
+			t.Fatalf("unexpected headers on message %s: %v", body, msg.Header)
+		}
+	}
+}
+
+func TestCLIPubHeaderFile(t *testing.T) {
+	srv, nc, _ := setupJStreamTest(t)
+	defer srv.Shutdown()
+
+	hdrFile, err := ioutil.TempFile("", "")
+	checkErr(t, err, "temp file failed")
+	defer os.Remove(hdrFile.Name())
+
+	fmt.Fprintf(hdrFile, "# test headers\n\nX-One: file\nX-Two: file\n")
+	hdrFile.Close()
+
+	sub, err := nc.SubscribeSync("test")
+	checkErr(t, err, "subscribe failed")
+	nc.Flush()
+
+	runNatsCli(t, fmt.Sprintf("--server='%s' pub test hello --header-file %s -H 'X-Two: flag'", srv.ClientURL(), hdrFile.Name()))
+
+	msg, err := sub.NextMsg(time.Second)
+	checkErr(t, err, "no message received: %s", err)
+
+	if msg.Header.Get("X-One") != "file" || len(msg.Header["X-Two"]) != 1 || msg.Header.Get("X-Two") != "flag" {
+		t.Fatalf("invalid headers received: %v", msg.Header)
+	}
+}
+
+// runNatsCliTerminal runs the utility with a pseudo terminal as STDIN and STDOUT using script(1)
+func runNatsCliTerminal(t *testing.T, args ...string) (output []byte) {
+	t.Helper()
+
+	if _, err := exec.LookPath("script"); err != nil {
+		t.Skip("script is not available to create a terminal")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	execution := exec.CommandContext(ctx, "script", "-qec", natsCliCommand(args...), "/dev/null")
+	out, err := execution.CombinedOutput()
+	if err != nil {
+		t.Fatalf("nats utility failed: %v\n%v", err, string(out))
+	}
+
+	return out
+}
+
+func TestCLIPubFile(t *testing.T) {
+	srv, nc, _ := setupJStreamTest(t)
+	defer srv.Shutdown()
+
+	body, err := ioutil.TempFile("", "")
+	checkErr(t, err, "temp file failed")
+	defer os.Remove(body.Name())
+
+	fmt.Fprint(body, "payload from file")
+	body.Close()
+
+	sub, err := nc.SubscribeSync("test")
+	checkErr(t, err, "subscribe failed")
+	nc.Flush()
+
+	for name, run := range map[string]func(*testing.T, ...string) []byte{"pipe": runNatsCli, "terminal": runNatsCliTerminal} {
+		t.Run(name, func(t *testing.T) {
+			run(t, fmt.Sprintf("--server='%s' pub test --file %s", srv.ClientURL(), body.Name()))
+
+			msg, err := sub.NextMsg(time.Second)
+			checkErr(t, err, "no message received: %s", err)
+
+			if string(msg.Data) != "payload from file" {
+				t.Fatalf("expected the file contents got %q", msg.Data)
+			}
+		})
+	}
+}
+
+func TestCLISubCount(t *testing.T) {
+	srv, nc, _ := setupJStreamTest(t)
+	defer srv.Shutdown()
+
+	outc := make(chan []byte, 1)
+	go func() {
+		outc <- runNatsCli(t, fmt.Sprintf("--server='%s' sub test --count 2 --raw --wait 8s", srv.ClientURL()))
+	}()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.After(15 * time.Second)
+
+	for {
+		select {
+		case <-deadline:
+			t.Fatalf("sub did not exit after receiving 2 messages")
+
+		case out := <-outc:
+			if strings.Count(string(out), "hello\n") != 2 {
+				t.Fatalf("expected 2 messages, got: %s", string(out))
+			}
+			return
+
+		case <-ticker.C:
+			nc.Publish("test", []byte("hello"))
+		}
+	}
+}
+
+func TestCLIConnectionInfo(t *testing.T) {
+	srv, _, _ := setupJStreamTest(t)
+	defer srv.Shutdown()
+
+	out := runNatsCli(t, fmt.Sprintf("--server='%s' connection info", srv.ClientURL()))
+
+	for _, expect := range []string{"Server ID: " + srv.ID(), "JetStream: true", "Auth Required: false", "Auth Method: None", "TLS Required: false"} {
+		if !strings.Contains(string(out), expect) {
+			t.Fatalf("expected %q in output: %s", expect, out)
+		}
+	}
+
+	usrv, err := server.NewServer(&server.Options{Port: -1, Users: []*server.User{{Username: "bob"}}})
+	checkErr(t, err, "could not start server: %v", err)
+	go usrv.Start()
+	defer usrv.Shutdown()
+	if !usrv.ReadyForConnections(10 * time.Second) {
+		t.Fatalf("nats server did not start")
+	}
+
+	out = runNatsCli(t, fmt.Sprintf("--server='%s' --user bob connection info", usrv.ClientURL()))
+	if !strings.Contains(string(out), "Auth Method: User bob without password") {
+		t.Fatalf("expected user without password auth in output: %s", out)
+	}
+}
+
+func TestCLISubAckCount(t *testing.T) {
+	srv, nc, mgr := setupConsTest(t)
+	defer srv.Shutdown()
+
+	for i := 1; i <= 3; i++ {
+		_, err := nc.Request("js.mem.1", []byte(fmt.Sprintf("msg%d", i)), time.Second)
+		checkErr(t, err, "publish failed: %v", err)
+	}
+
+	subs := srv.NumSubscriptions()
+	outc := make(chan []byte, 1)
+	go func() {
+		outc <- runNatsCli(t, fmt.Sprintf("--server='%s' sub out.ack --ack --count 1 --raw", srv.ClientURL()))
+	}()
+
+	deadline := time.Now().Add(15 * time.Second)
+	for srv.NumSubscriptions() == subs {
+		if time.Now().After(deadline) {
+			t.Fatalf("sub did not subscribe")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	consumer, err := mgr.NewConsumer("mem1", jsm.DurableName("ack"), jsm.DeliverySubject("out.ack"), jsm.AcknowledgeExplicit())
+	checkErr(t, err, "could not create consumer: %v", err)
+
+	out := <-outc
+	if strings.TrimSpace(string(out)) != "msg1" {
+		t.Fatalf("expected only msg1 got: %s", out)
+	}
+
+	state, err := consumer.State()
+	checkErr(t, err, "could not load consumer state: %v", err)
+
+	if state.AckFloor.Stream != 1 {
+		t.Fatalf("expected only message 1 to be acknowledged, ack floor is %d", state.AckFloor.Stream)
+	}
+}
+
+func TestCLISubOrdered(t *testing.T) {
+	srv, nc, _ := setupConsTest(t)
+	defer srv.Shutdown()
+
+	for i := 1; i <= 3; i++ {
+		_, err := nc.Request("js.mem.1", []byte(fmt.Sprintf("msg%d", i)), time.Second)
+		checkErr(t, err, "publish failed: %v", err)
+	}
+
+	out := runNatsCli(t, fmt.Sprintf("--server='%s' sub 'js.mem.>' --ordered --start-seq 2 --count 2 --raw --wait 5s", srv.ClientURL()))
+	if string(out) != "msg2\nmsg3\n" {
+		t.Fatalf("expected msg2 and msg3 in order, got: %q", out)
+	}
+}
+
+func TestCLIStreamFind(t *testing.T) {
+	srv, nc, mgr := setupJStreamTest(t)
+	defer srv.Shutdown()
+
+	_, err := mgr.NewStreamFromDefault("mem1", mem1Stream())
+	checkErr(t, err, "could not create stream: %v", err)
+	_, err = mgr.NewStreamFromDefault("file1", file1Stream())
+	checkErr(t, err, "could not create stream: %v", err)
+
+	_, err = nc.Request("js.mem.1", []byte("hello"), time.Second)
+	checkErr(t, err, "publish failed: %v", err)
+
+	out := runNatsCli(t, fmt.Sprintf("--server='%s' str find --storage memory", srv.ClientURL()))
+	if string(out) != "mem1\n" {
+		t.Fatalf("expected mem1 got %q", string(out))
+	}
+
+	out = runNatsCli(t, fmt.Sprintf("--server='%s' str find --empty --replicas 1", srv.ClientURL()))
+	if string(out) != "file1\n" {
+		t.Fatalf("expected file1 got %q", string(out))
+	}
+}
@@ -36,6 +36,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/nats-io/nats.go"
 )
@@ -55,6 +56,7 @@ type settings struct {
 	Key         string `json:"key"`
 	CA          string `json:"ca"`
 	NSCLookup   string `json:"nsc"`
+	Timeout     string `json:"timeout,omitempty"`
 }
 
 type Context struct {
@@ -542,3 +544,28 @@ func (c *Context) Description() string { return c.config.Description }
 
 // Path returns the path on disk for a loaded context, empty when not saved or loaded
 func (c *Context) Path() string { return c.path }
+
+// WithTimeout sets the default time to wait on responses from NATS, 0 removes a previously set timeout
+func WithTimeout(t time.Duration) Option {
+	return func(s *settings) {
+		if t > 0 {
+			s.Timeout = t.String()
+		} else {
+			s.Timeout = ""
+		}
+	}
+}
+
+// Timeout is the default time to wait on responses from NATS, 0 when not set or invalid
+func (c *Context) Timeout() time.Duration {
+	if c.config.Timeout == "" {
+		return 0
+	}
+
+	t, err := time.ParseDuration(c.config.Timeout)
+	if err != nil {
+		return 0
+	}
+
+	return t
+}
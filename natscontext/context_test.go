@@ -3,6 +3,7 @@ package natscontext_test
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/nats-io/nats.go"
 
@@ -70,6 +71,18 @@ func TestContext(t *testing.T) {
 		t.Fatalf("expected ngs got %s", config.ServerURL())
 	}
 
+	if config.Timeout() != 0 {
+		t.Fatalf("expected no timeout got %v", config.Timeout())
+	}
+
+	config, err = natscontext.New("", true, natscontext.WithTimeout(10*time.Second))
+	if err != nil {
+		t.Fatalf("error loading context: %s", err)
+	}
+	if config.Timeout() != 10*time.Second {
+		t.Fatalf("expected 10s timeout got %v", config.Timeout())
+	}
+
 	// support missing config/context
 	os.Setenv("XDG_CONFIG_HOME", "/nonexisting")
 	config, err = natscontext.New("", true)
@@ -15,27 +15,82 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"math"
+	"math/rand"
 	"os"
+	"path/filepath"
 	"strings"
 	"text/template"
 	"time"
 
+	"github.com/dustin/go-humanize"
+	"github.com/nats-io/jsm.go/api"
 	"github.com/nats-io/nats.go"
+	"github.com/xlab/tablewriter"
 	"golang.org/x/crypto/ssh/terminal"
+	"google.golang.org/protobuf/reflect/protoreflect"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 type pubCmd struct {
-	subject string
-	body    string
-	req     bool
-	replyTo string
-	raw     bool
-	hdrs    []string
-	cnt     int
+	subject    string
+	body       string
+	req        bool
+	replyTo    string
+	raw        bool
+	hdrs       []string
+	cnt        int
+	sleep      time.Duration
+	file       string
+	jsAck      bool
+	replies    int
+	json       bool
+	hdrFile    string
+	fHdrs      []string
+	bpsRate    string
+	fromDir    string
+	rate       int
+	binary     bool
+	outFile    string
+	out        io.WriteCloser
+	decomp     string
+	retries    int
+	retryDelay time.Duration
+	protoFile  string
+	protoMsg   string
+	protoReply string
+	expectResp bool
+	waitReply  bool
+	interval   time.Duration
+	jitter     time.Duration
+	dur        time.Duration
+	stream     bool
+	streamEnd  string
+	msgType    protoreflect.MessageDescriptor
+	replyType  protoreflect.MessageDescriptor
+}
+
+// reqReply is the JSON representation of a reply received by the request command
+type reqReply struct {
+	Subject string              `json:"subject"`
+	RTT     time.Duration       `json:"rtt"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	Data    []byte              `json:"data,omitempty"`
+	Error   string              `json:"error,omitempty"`
+}
+
+func newReqReply(m *nats.Msg, rtt time.Duration) *reqReply {
+	return &reqReply{
+		Subject: m.Subject,
+		RTT:     rtt,
+		Headers: m.Header,
+		Data:    m.Data,
+	}
 }
 
 func configurePubCommand(app *kingpin.Application) {
@@ -56,34 +111,154 @@ Available template variables are:
    .UnixNano  nano seconds since 1970 in UTC
    .Time      the current time
 
+Available template functions are:
+
+   Env "NAME"        the value of the NAME environment variable
+   Random min max    a random number between min and max
+
+   nats pub test --count 10 'Message {{.Cnt}} from {{Env "USER"}} value {{Random 1 100}}'
+
 `
 	pub := app.Command("pub", help).Action(c.publish)
 	pub.Arg("subject", "Subject to subscribe to").Required().StringVar(&c.subject)
 	pub.Arg("body", "Message body").Default("!nil!").StringVar(&c.body)
 	pub.Flag("wait", "Wait for a reply from a service").Short('w').BoolVar(&c.req)
 	pub.Flag("reply", "Sets a custom reply to subject").StringVar(&c.replyTo)
+	pub.Flag("wait-reply", "Subscribes to the --reply subject and shows messages received on it until the timeout").BoolVar(&c.waitReply)
 	pub.Flag("header", "Adds headers to the message").Short('H').StringsVar(&c.hdrs)
+	pub.Flag("header-file", "Adds headers from a file of Key: Value lines").PlaceHolder("FILE").ExistingFileVar(&c.hdrFile)
 	pub.Flag("count", "Publish multiple messages").Default("1").IntVar(&c.cnt)
+	pub.Flag("sleep", "When publishing multiple messages, sleep between publishes").DurationVar(&c.sleep)
+	pub.Flag("interval", "When publishing multiple messages, publish one every interval regardless of how long publishing takes").PlaceHolder("DURATION").DurationVar(&c.interval)
+	pub.Flag("duration", "Publishes for this long, limited by --count only when it is also given").PlaceHolder("DURATION").DurationVar(&c.dur)
+	pub.Flag("jitter", "Delays every --interval publish by a random duration up to this maximum").PlaceHolder("MAX").DurationVar(&c.jitter)
+	pub.Flag("file", "Reads the message body from a file, - for STDIN").PlaceHolder("FILE").StringVar(&c.file)
+	pub.Flag("js-ack", "Waits for a JetStream Stream to acknowledge the message").BoolVar(&c.jsAck)
+	pub.Flag("expect-responder", "Fails when there are no subscribers to receive the message").BoolVar(&c.expectResp)
+	pub.Flag("from-dir", "Publishes the contents of every file in a directory as a message, headers are read from FILE.headers when present").PlaceHolder("DIR").ExistingDirVar(&c.fromDir)
+	pub.Flag("rate", "When publishing from a directory, publish this many messages per second").IntVar(&c.rate)
+	pub.Flag("max-bytes-rate", "When publishing multiple messages, limit the message bodies sent per second (eg. 1MB)").PlaceHolder("BYTES").StringVar(&c.bpsRate)
+	pub.Flag("proto", "Compiled protobuf FileDescriptorSet used to encode JSON bodies, see --message").PlaceHolder("DESCRIPTOR").ExistingFileVar(&c.protoFile)
+	pub.Flag("message", "Protobuf message type to encode JSON bodies as").PlaceHolder("TYPE").StringVar(&c.protoMsg)
+
+	reqHelp := `Generic data request utility
+
+Multiple subjects can be given comma separated, a request will
+be sent to each and a summary of replies will be shown.
+
+   nats request service.a,service.b ping
+
+The global --timeout flag sets how long to wait for replies
+for this invocation only, overriding the context setting.
 
-	req := app.Command("request", "Generic data request utility").Alias("req").Action(c.publish)
-	req.Arg("subject", "Subject to subscribe to").Required().StringVar(&c.subject)
+   nats request service.slow ping --timeout 30s
+
+Services that send large replies in chunks can be read using
+--stream, replies are combined till an empty reply or one with
+the --stream-end header is received, each chunk has to arrive
+within the timeout.
+
+   nats request service.export all --stream --out export.json
+`
+
+	req := app.Command("request", reqHelp).Alias("req").Action(c.publish)
+	req.Arg("subject", "Subject to send the request to, comma separated for multiple").Required().StringVar(&c.subject)
 	req.Arg("body", "Message body").Default("!nil!").StringVar(&c.body)
 	req.Flag("wait", "Wait for a reply from a service").Short('w').Default("true").Hidden().BoolVar(&c.req)
 	req.Flag("raw", "Show just the output received").Short('r').Default("false").BoolVar(&c.raw)
 	req.Flag("header", "Adds headers to the message").Short('H').StringsVar(&c.hdrs)
+	req.Flag("header-file", "Adds headers from a file of Key: Value lines").PlaceHolder("FILE").ExistingFileVar(&c.hdrFile)
+	req.Flag("replies", "Wait for up to this many replies from multiple responders").Default("1").IntVar(&c.replies)
+	req.Flag("json", "Produce JSON output containing the reply subject, rtt, headers and base64 encoded body").Short('j').BoolVar(&c.json)
+	req.Flag("binary", "Writes the reply body to STDOUT exactly as received").BoolVar(&c.binary)
+	req.Flag("out", "Writes the reply body to a file exactly as received").PlaceHolder("FILE").StringVar(&c.outFile)
+	req.Flag("decompress", "Decompresses reply bodies using gzip before showing them").PlaceHolder("gzip").EnumVar(&c.decomp, "gzip")
+	req.Flag("proto", "Compiled protobuf FileDescriptorSet used to encode JSON bodies and decode replies").PlaceHolder("DESCRIPTOR").ExistingFileVar(&c.protoFile)
+	req.Flag("message", "Protobuf message type to encode JSON bodies as").PlaceHolder("TYPE").StringVar(&c.protoMsg)
+	req.Flag("reply-message", "Protobuf message type to decode replies from").PlaceHolder("TYPE").StringVar(&c.protoReply)
+	req.Flag("retry", "Retry the request this many times when there are no responders or it times out").PlaceHolder("N").IntVar(&c.retries)
+	req.Flag("retry-delay", "Delay before the first retry, doubled after every attempt").Default("500ms").DurationVar(&c.retryDelay)
+	req.Flag("stream", "Combines chunked replies received until an empty reply or the --stream-end header").BoolVar(&c.stream)
+	req.Flag("stream-end", "Header that marks the final chunk of a --stream reply").PlaceHolder("HEADER").StringVar(&c.streamEnd)
 }
 
-func (c *pubCmd) prepareMsg(body []byte) (*nats.Msg, error) {
+func pubTemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"Env": os.Getenv,
+		"Random": func(min int, max int) int {
+			if max <= min {
+				return min
+			}
+
+			return rand.Intn(max-min+1) + min
+		},
+	}
+}
+
+// prepareMsg creates the message with headers from the header file, the optional sidecar headers and -H applied in that
+// order, each replacing headers of the same name set before it
+func (c *pubCmd) prepareMsg(body []byte, sidecar ...string) (*nats.Msg, error) {
 	msg := nats.NewMsg(c.subject)
 	msg.Reply = c.replyTo
 	msg.Data = body
 
-	return msg, parseStringsToHeader(c.hdrs, msg)
+	if c.msgType != nil && len(body) > 0 {
+		var err error
+		msg.Data, err = protoEncode(c.msgType, body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, set := range [][]string{c.fHdrs, sidecar, c.hdrs} {
+		hdrs := nats.NewMsg(c.subject)
+		err := parseStringsToHeader(set, hdrs)
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range hdrs.Header {
+			msg.Header[k] = v
+		}
+	}
+
+	return msg, nil
 }
 
 func (c *pubCmd) doReq(nc *nats.Conn) error {
-	start := time.Now()
-	if !c.raw {
+	if c.streamEnd != "" && !c.stream {
+		return fmt.Errorf("--stream-end requires --stream")
+	}
+
+	if c.stream && (c.json || c.replies > 1 || c.retries > 0) {
+		return fmt.Errorf("--stream cannot be used with --json, --replies or --retry")
+	}
+
+	subjects := splitString(c.subject)
+	if len(subjects) > 1 {
+		if c.binary || c.outFile != "" || c.stream {
+			return fmt.Errorf("--binary, --out and --stream cannot be used with multiple subjects")
+		}
+
+		return c.doMultiReq(nc, subjects)
+	}
+
+	if c.binary || c.outFile != "" {
+		if c.json {
+			return fmt.Errorf("--json cannot be used with --binary or --out")
+		}
+
+		var err error
+		c.out, err = rawOutput(c.binary, c.outFile)
+		if err != nil {
+			return err
+		}
+		defer c.out.Close()
+
+		c.raw = true
+	}
+
+	if !c.raw && !c.json {
 		log.Printf("Sending request on %q\n", c.subject)
 	}
 
@@ -92,18 +267,187 @@ func (c *pubCmd) doReq(nc *nats.Conn) error {
 		return err
 	}
 
-	m, err := nc.RequestMsg(msg, timeout)
+	if c.replies > 1 {
+		return c.collectReplies(nc, msg)
+	}
+
+	if c.stream {
+		return c.streamReply(nc, msg)
+	}
+
+	m, rtt, err := c.requestWithRetry(nc, msg)
+	if err != nil {
+		return err
+	}
+	m.Data = protoDecode(c.replyType, decompressBody(c.decomp, m.Data))
+
+	if c.json {
+		return printJSON(newReqReply(m, rtt))
+	}
+
+	c.showReply(m, rtt)
+
+	return nil
+}
+
+// requestWithRetry performs the request retrying up to c.retries times on timeouts and missing responders with
+// an increasing delay between attempts, the rtt of the successful attempt is returned
+func (c *pubCmd) requestWithRetry(nc *nats.Conn, msg *nats.Msg) (*nats.Msg, time.Duration, error) {
+	delay := c.retryDelay
+
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		m, err := nc.RequestMsg(msg, timeout)
+		if err == nil {
+			return m, time.Since(start), nil
+		}
+
+		if attempt > c.retries || (err != nats.ErrNoResponders && err != nats.ErrTimeout) {
+			return nil, 0, err
+		}
+
+		log.Printf("Request attempt %d of %d failed: %s, retrying in %v", attempt, c.retries+1, err, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// collectReplies publishes msg with an inbox as reply subject and shows up to c.replies responses received before the timeout
+func (c *pubCmd) collectReplies(nc *nats.Conn, msg *nats.Msg) error {
+	sub, err := nc.SubscribeSync(nats.NewInbox())
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	msg.Reply = sub.Subject
+	deadline := time.Now().Add(timeout)
+	start := time.Now()
+
+	err = nc.PublishMsg(msg)
 	if err != nil {
 		return err
 	}
 
+	var results []*reqReply
+	received := 0
+	for received < c.replies {
+		m, err := sub.NextMsg(time.Until(deadline))
+		if err == nats.ErrTimeout {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(m.Data) == 0 && m.Header.Get("Status") == "503" {
+			return nats.ErrNoResponders
+		}
+
+		received++
+		m.Data = protoDecode(c.replyType, decompressBody(c.decomp, m.Data))
+
+		if c.json {
+			results = append(results, newReqReply(m, time.Since(start)))
+		} else {
+			c.showReply(m, time.Since(start))
+		}
+	}
+
+	if received == 0 {
+		return nats.ErrTimeout
+	}
+
+	if c.json {
+		return printJSON(results)
+	}
+
+	if !c.raw {
+		log.Printf("Received %d of %d expected replies", received, c.replies)
+	}
+
+	return nil
+}
+
+// streamReply publishes msg and combines the replies till an empty one or one with the c.streamEnd header is received
+func (c *pubCmd) streamReply(nc *nats.Conn, msg *nats.Msg) error {
+	sub, err := nc.SubscribeSync(nats.NewInbox())
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	msg.Reply = sub.Subject
+	start := time.Now()
+
+	err = nc.PublishMsg(msg)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	chunks := 0
+
+	for {
+		m, err := sub.NextMsg(timeout)
+		if err == nats.ErrTimeout && chunks > 0 {
+			return fmt.Errorf("timeout waiting for chunk %d after receiving %s", chunks+1, humanize.IBytes(uint64(body.Len())))
+		}
+		if err != nil {
+			return err
+		}
+
+		if chunks == 0 && len(m.Data) == 0 && m.Header.Get("Status") == "503" {
+			return nats.ErrNoResponders
+		}
+
+		if len(m.Data) > 0 {
+			body.Write(m.Data)
+			chunks++
+		}
+
+		if len(m.Data) == 0 || (c.streamEnd != "" && m.Header.Get(c.streamEnd) != "") {
+			break
+		}
+	}
+
+	if !c.raw {
+		log.Printf("Received %s in %d chunks from %q rtt %v", humanize.IBytes(uint64(body.Len())), chunks, msg.Subject, time.Since(start))
+		fmt.Println()
+	}
+
+	data := protoDecode(c.replyType, decompressBody(c.decomp, body.Bytes()))
+
+	if c.out != nil {
+		_, err = c.out.Write(data)
+		return err
+	}
+
+	os.Stdout.Write(data)
+	if !bytes.HasSuffix(data, []byte("\n")) {
+		fmt.Println()
+	}
+
+	return nil
+}
+
+func (c *pubCmd) showReply(m *nats.Msg, rtt time.Duration) {
+	if c.out != nil {
+		_, err := c.out.Write(m.Data)
+		if err != nil {
+			log.Printf("Could not write reply: %s", err)
+		}
+
+		return
+	}
+
 	if c.raw {
 		fmt.Println(string(m.Data))
 
-		return nil
+		return
 	}
 
-	log.Printf("Received on %q rtt %v", m.Subject, time.Since(start))
+	log.Printf("Received on %q rtt %v", m.Subject, rtt)
 	if len(m.Header) > 0 {
 		for h, vals := range m.Header {
 			for _, val := range vals {
@@ -118,18 +462,322 @@ func (c *pubCmd) doReq(nc *nats.Conn) error {
 	if !strings.HasSuffix(string(m.Data), "\n") {
 		fmt.Println()
 	}
+}
+
+func (c *pubCmd) doMultiReq(nc *nats.Conn, subjects []string) error {
+	table := tablewriter.CreateTable()
+	table.AddTitle("Request Replies")
+	table.AddHeaders("Subject", "RTT", "Size", "Error")
+
+	var results []*reqReply
+	for _, subj := range subjects {
+		msg, err := c.prepareMsg([]byte(c.body))
+		if err != nil {
+			return err
+		}
+		msg.Subject = subj
+
+		start := time.Now()
+		m, err := nc.RequestMsg(msg, timeout)
+		if err != nil {
+			results = append(results, &reqReply{Subject: subj, Error: err.Error()})
+			table.AddRow(subj, "", "", err.Error())
+			continue
+		}
+
+		rtt := time.Since(start)
+		results = append(results, newReqReply(m, rtt))
+		table.AddRow(subj, rtt.Round(time.Microsecond), humanize.IBytes(uint64(len(m.Data))), "")
+	}
+
+	if c.json {
+		return printJSON(results)
+	}
+
+	fmt.Println(table.Render())
+
+	return nil
+}
+
+func (c *pubCmd) corePublish(nc *nats.Conn, msg *nats.Msg) error {
+	if c.expectResp {
+		return c.checkedPublish(nc, msg)
+	}
+
+	err := nc.PublishMsg(msg)
+	if err != nil {
+		return err
+	}
+	nc.Flush()
+
+	return nc.LastError()
+}
+
+// checkedPublish publishes msg with a reply subject so the server reports a no responders status when nothing is
+// subscribed to the subject, the status is sent before the server answers the flush
+func (c *pubCmd) checkedPublish(nc *nats.Conn, msg *nats.Msg) error {
+	if !nc.HeadersSupported() {
+		return fmt.Errorf("--expect-responder requires a server that supports headers")
+	}
+
+	sub, err := nc.SubscribeSync(nats.NewInbox())
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	msg.Reply = sub.Subject
+	err = nc.PublishMsg(msg)
+	if err != nil {
+		return err
+	}
+
+	err = nc.Flush()
+	if err != nil {
+		return err
+	}
+
+	pending, _, err := sub.Pending()
+	if err != nil {
+		return err
+	}
+
+	if pending > 0 {
+		m, err := sub.NextMsg(timeout)
+		if err == nil && len(m.Data) == 0 && m.Header.Get("Status") == "503" {
+			return fmt.Errorf("no subscribers are listening on %q", msg.Subject)
+		}
+	}
+
+	return nc.LastError()
+}
+
+func (c *pubCmd) jsPublish(nc *nats.Conn, msg *nats.Msg) (*api.PubAck, error) {
+	resp, err := nc.RequestMsg(msg, timeout)
+	switch {
+	case err == nats.ErrNoResponders, err == nats.ErrTimeout:
+		return nil, fmt.Errorf("no acknowledgement received, ensure a Stream is configured to receive messages on %q: %s", msg.Subject, err)
+	case err != nil:
+		return nil, err
+	}
+
+	ack := api.JSPubAckResponse{}
+	err = json.Unmarshal(resp.Data, &ack)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JetStream acknowledgement %q: %s", resp.Data, err)
+	}
+
+	if ack.Error != nil {
+		return nil, ack.Error
+	}
+
+	if ack.Stream == "" {
+		return nil, fmt.Errorf("invalid JetStream acknowledgement %q", resp.Data)
+	}
+
+	return &ack.PubAck, nil
+}
+
+// sendMsg publishes msg, waiting for the JetStream acknowledgement when --js-ack is set
+func (c *pubCmd) sendMsg(nc *nats.Conn, msg *nats.Msg) error {
+	if c.jsAck {
+		ack, err := c.jsPublish(nc, msg)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Published %d bytes to %q, stored in Stream %s sequence %d\n", len(msg.Data), c.subject, ack.Stream, ack.Sequence)
+		return nil
+	}
+
+	err := c.corePublish(nc, msg)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Published %d bytes to %q\n", len(msg.Data), c.subject)
 
 	return nil
 }
 
-func (c *pubCmd) publish(_ *kingpin.ParseContext) error {
-	nc, err := newNatsConn("", natsOpts()...)
+// publishDir publishes every file in c.fromDir in name order, FILE.headers holds headers for FILE in the --header-file format
+func (c *pubCmd) publishDir(nc *nats.Conn, limiter *byteRateLimiter) error {
+	files, err := ioutil.ReadDir(c.fromDir)
+	if err != nil {
+		return err
+	}
+
+	var delay time.Duration
+	if c.rate > 0 {
+		delay = time.Second / time.Duration(c.rate)
+	}
+
+	cnt := 0
+	for _, f := range files {
+		if f.IsDir() || strings.HasSuffix(f.Name(), ".headers") {
+			continue
+		}
+
+		path := filepath.Join(c.fromDir, f.Name())
+		body, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		var sidecar []string
+		if _, err := os.Stat(path + ".headers"); err == nil {
+			sidecar, err = readHeaderFile(path + ".headers")
+			if err != nil {
+				return err
+			}
+		}
+
+		msg, err := c.prepareMsg(body, sidecar...)
+		if err != nil {
+			return fmt.Errorf("invalid headers for %s: %s", path, err)
+		}
+
+		if cnt > 0 && delay > 0 {
+			time.Sleep(delay)
+		}
+
+		if limiter != nil {
+			limiter.Wait(len(msg.Data))
+		}
+
+		err = c.sendMsg(nc, msg)
+		if err != nil {
+			return err
+		}
+
+		cnt++
+	}
+
+	log.Printf("Published %d messages from %s", cnt, c.fromDir)
+
+	return nil
+}
+
+// byteRateLimiter is a token bucket that holds at most one second worth of bytes
+type byteRateLimiter struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newByteRateLimiter(bps uint64) *byteRateLimiter {
+	return &byteRateLimiter{rate: float64(bps), last: time.Now()}
+}
+
+// Wait blocks until n bytes may be sent without exceeding the rate
+func (l *byteRateLimiter) Wait(n int) {
+	now := time.Now()
+	l.tokens = math.Min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+
+	if l.tokens < 0 {
+		time.Sleep(time.Duration(-l.tokens / l.rate * float64(time.Second)))
+	}
+}
+
+// loadProtoTypes finds the protobuf message types to encode bodies as and decode replies from
+func (c *pubCmd) loadProtoTypes() (err error) {
+	if c.protoFile == "" {
+		if c.protoMsg != "" || c.protoReply != "" {
+			return fmt.Errorf("protobuf message types require a descriptor set to be given using --proto")
+		}
+
+		return nil
+	}
+
+	if c.protoMsg == "" && c.protoReply == "" {
+		return fmt.Errorf("--proto requires a message type")
+	}
+
+	if c.protoMsg != "" {
+		c.msgType, err = protoMessageType(c.protoFile, c.protoMsg)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.protoReply != "" {
+		c.replyType, err = protoMessageType(c.protoFile, c.protoReply)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *pubCmd) publish(pc *kingpin.ParseContext) error {
+	if c.file != "" && c.body != "!nil!" {
+		return fmt.Errorf("can not supply both a message body and --file")
+	}
+
+	if c.fromDir != "" && (c.file != "" || c.body != "!nil!" || c.cnt > 1 || c.req) {
+		return fmt.Errorf("can not supply a message body, --file, --count or --wait with --from-dir")
+	}
+
+	if c.expectResp && (c.replyTo != "" || c.jsAck || c.req) {
+		return fmt.Errorf("--expect-responder can not be used with --reply, --js-ack or --wait")
+	}
+
+	if c.dur > 0 && (c.fromDir != "" || c.req) {
+		return fmt.Errorf("--duration can not be used with --from-dir or --wait")
+	}
+
+	if c.interval > 0 && c.sleep > 0 {
+		return fmt.Errorf("--interval and --sleep can not be used together")
+	}
+
+	if c.jitter > 0 && c.interval == 0 {
+		return fmt.Errorf("--jitter requires --interval")
+	}
+
+	if c.waitReply && (c.replyTo == "" || c.jsAck || c.req) {
+		return fmt.Errorf("--wait-reply requires --reply and can not be used with --js-ack or --wait")
+	}
+
+	if c.hdrFile != "" {
+		var err error
+		c.fHdrs, err = readHeaderFile(c.hdrFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := c.loadProtoTypes()
+	if err != nil {
+		return err
+	}
+
+	// validate headers before connecting so mistakes are reported early
+	_, err = c.prepareMsg(nil)
+	if err != nil {
+		return err
+	}
+
+	nc, err := newNatsConn("", append(natsOpts(), traceOpts()...)...)
 	if err != nil {
 		return err
 	}
 	defer nc.Close()
 
-	if c.body == "!nil!" && terminal.IsTerminal(int(os.Stdout.Fd())) {
+	switch {
+	case c.fromDir != "":
+		// bodies are read from each file while publishing
+
+	case c.file != "" && c.file != "-":
+		body, err := ioutil.ReadFile(c.file)
+		if err != nil {
+			return err
+		}
+		c.body = string(body)
+
+	case c.file == "-", c.body == "!nil!" && terminal.IsTerminal(int(os.Stdout.Fd())):
 		log.Println("Reading payload from STDIN")
 		body, err := ioutil.ReadAll(os.Stdin)
 		if err != nil {
@@ -150,27 +798,84 @@ func (c *pubCmd) publish(_ *kingpin.ParseContext) error {
 		Time      string
 	}
 
-	t, err := template.New("body").Parse(c.body)
-	if err != nil {
-		return err
-	}
-
 	if c.cnt < 1 {
 		c.cnt = 1
 	}
 
+	if c.dur > 0 && !flagGiven(pc, "count") {
+		c.cnt = math.MaxInt32
+	}
+
+	var limiter *byteRateLimiter
+	if c.bpsRate != "" {
+		bps, err := humanize.ParseBytes(c.bpsRate)
+		if err != nil {
+			return fmt.Errorf("invalid --max-bytes-rate: %s", err)
+		}
+		if bps == 0 {
+			return fmt.Errorf("--max-bytes-rate must be greater than 0")
+		}
+
+		limiter = newByteRateLimiter(bps)
+	}
+
+	if c.fromDir != "" {
+		return c.publishDir(nc, limiter)
+	}
+
+	// file contents are sent as is unless we are publishing many messages
+	useTemplate := c.file == "" || c.cnt > 1
+
+	var t *template.Template
+	if useTemplate {
+		t, err = template.New("body").Funcs(pubTemplateFuncs()).Parse(c.body)
+		if err != nil {
+			return err
+		}
+	}
+
+	var replies *nats.Subscription
+	if c.waitReply {
+		replies, err = nc.SubscribeSync(c.replyTo)
+		if err != nil {
+			return err
+		}
+		defer replies.Unsubscribe()
+
+		err = nc.Flush()
+		if err != nil {
+			return err
+		}
+	}
+
+	start := time.Now()
+
+	sent := 0
 	for i := 1; i <= c.cnt; i++ {
+		if c.interval > 0 {
+			c.waitForInterval(start, i)
+		}
+
+		if c.dur > 0 && time.Since(start) >= c.dur {
+			break
+		}
+
 		var body bytes.Buffer
 		now := time.Now()
-		err = t.Execute(&body, &pubData{
-			Cnt:       i,
-			Unix:      now.Unix(),
-			UnixNano:  now.UnixNano(),
-			TimeStamp: now.Format(time.RFC3339),
-			Time:      now.Format(time.Kitchen),
-		})
-		if err != nil {
-			return err
+
+		if useTemplate {
+			err = t.Execute(&body, &pubData{
+				Cnt:       i,
+				Unix:      now.Unix(),
+				UnixNano:  now.UnixNano(),
+				TimeStamp: now.Format(time.RFC3339),
+				Time:      now.Format(time.Kitchen),
+			})
+			if err != nil {
+				return err
+			}
+		} else {
+			body.WriteString(c.body)
 		}
 
 		msg, err := c.prepareMsg(body.Bytes())
@@ -178,20 +883,65 @@ func (c *pubCmd) publish(_ *kingpin.ParseContext) error {
 			return err
 		}
 
-		err = nc.PublishMsg(msg)
+		if limiter != nil {
+			limiter.Wait(len(msg.Data))
+		}
+
+		err = c.sendMsg(nc, msg)
 		if err != nil {
 			return err
 		}
-		nc.Flush()
 
-		err = nc.LastError()
+		sent++
+
+		if c.sleep > 0 && i < c.cnt {
+			time.Sleep(c.sleep)
+		}
+	}
+
+	if c.dur > 0 {
+		elapsed := time.Since(start)
+		log.Printf("Published %s messages in %v, %s msgs/sec", humanize.Comma(int64(sent)), elapsed.Round(time.Millisecond), humanize.Comma(int64(float64(sent)/elapsed.Seconds())))
+	}
+
+	if replies != nil {
+		return c.showFixedReplies(replies, start)
+	}
+
+	return nil
+
+}
+
+// waitForInterval sleeps until message number i is due, every message is scheduled one --interval after the previous
+// one relative to start with optional jitter so slow publishes do not delay the rest of the schedule
+func (c *pubCmd) waitForInterval(start time.Time, i int) {
+	due := start.Add(time.Duration(i-1) * c.interval)
+	if c.jitter > 0 {
+		due = due.Add(time.Duration(rand.Int63n(int64(c.jitter))))
+	}
+
+	time.Sleep(time.Until(due))
+}
+
+// showFixedReplies shows messages received on the --reply subject until the timeout passes without any arriving
+func (c *pubCmd) showFixedReplies(replies *nats.Subscription, start time.Time) error {
+	log.Printf("Waiting %v for replies on %q", timeout, c.replyTo)
+
+	received := 0
+	for {
+		m, err := replies.NextMsg(timeout)
+		if err == nats.ErrTimeout {
+			break
+		}
 		if err != nil {
 			return err
 		}
 
-		log.Printf("Published %d bytes to %q\n", len(c.body), c.subject)
+		received++
+		c.showReply(m, time.Since(start))
 	}
 
-	return nil
+	log.Printf("Received %d replies on %q", received, c.replyTo)
 
+	return nil
 }
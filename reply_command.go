@@ -14,14 +14,17 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"math/rand"
+	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/kballard/go-shellquote"
@@ -36,7 +39,9 @@ type replyCmd struct {
 	command string
 	echo    bool
 	sleep   time.Duration
+	delay   time.Duration
 	hdrs    []string
+	id      string
 }
 
 func configureReplyCommand(app *kingpin.Application) {
@@ -53,6 +58,21 @@ the "london" part and use it in the command string:
 This will request the weather for london when invoked as:
 
   nats request weather.london ''
+
+The body can be a Go template with the following variables, functions
+from the pub command can also be used:
+
+   .Request   the body of the request
+   .Subject   the subject the request was received on
+   .Header    the headers of the request
+   .Count     the number of requests received
+   .Instance  the instance id set using --instance
+
+Several replies sharing the same --queue group will load balance requests
+between them, each reply includes a NATS-Reply-Instance header to identify
+the instance that handled it.
+
+  nats reply 'service.>' 'Request {{.Count}} on {{.Subject}}: {{.Request}}'
 `
 	act := app.Command("reply", help).Action(c.reply)
 	act.Arg("subject", "Subject to subscribe to").Required().StringVar(&c.subject)
@@ -60,7 +80,9 @@ This will request the weather for london when invoked as:
 	act.Flag("echo", "Echo back what is received").BoolVar(&c.echo)
 	act.Flag("command", "Runs a command and responds with the output if exit code was 0").StringVar(&c.command)
 	act.Flag("queue", "Queue group name").Default("NATS-RPLY-22").Short('q').StringVar(&c.queue)
+	act.Flag("instance", "Instance id to report in replies, defaults to the hostname and pid").PlaceHolder("ID").StringVar(&c.id)
 	act.Flag("sleep", "Inject a random sleep delay between replies up to this duration max").PlaceHolder("MAX").DurationVar(&c.sleep)
+	act.Flag("delay", "Delay every reply by this duration, combines with --sleep").PlaceHolder("DURATION").DurationVar(&c.delay)
 	act.Flag("header", "Adds headers to the message").Short('H').StringsVar(&c.hdrs)
 }
 
@@ -75,9 +97,33 @@ func (c *replyCmd) reply(_ *kingpin.ParseContext) error {
 		c.echo = true
 	}
 
+	if c.id == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			host = "unknown"
+		}
+		c.id = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+
+	type replyData struct {
+		Request  string
+		Subject  string
+		Header   http.Header
+		Count    int
+		Instance string
+	}
+
+	var body *template.Template
+	if c.body != "" {
+		body, err = template.New("body").Funcs(pubTemplateFuncs()).Parse(c.body)
+		if err != nil {
+			return err
+		}
+	}
+
 	i := 0
 	nc.QueueSubscribe(c.subject, c.queue, func(m *nats.Msg) {
-		log.Printf("[#%d] Received on subject %q:", i, m.Subject)
+		log.Printf("[#%d] Received on subject %q by instance %q:", i+1, m.Subject, c.id)
 		for h, vals := range m.Header {
 			for _, val := range vals {
 				log.Printf("%s: %s", h, val)
@@ -87,6 +133,10 @@ func (c *replyCmd) reply(_ *kingpin.ParseContext) error {
 		fmt.Println()
 		fmt.Println(string(m.Data))
 
+		if c.delay > 0 {
+			time.Sleep(c.delay)
+		}
+
 		if c.sleep != 0 {
 			time.Sleep(time.Duration(rand.Intn(int(c.sleep))))
 		}
@@ -138,7 +188,24 @@ func (c *replyCmd) reply(_ *kingpin.ParseContext) error {
 			}
 
 		default:
-			msg.Data = []byte(c.body)
+			var b bytes.Buffer
+			err = body.Execute(&b, &replyData{
+				Request:  string(m.Data),
+				Subject:  m.Subject,
+				Header:   m.Header,
+				Count:    i + 1,
+				Instance: c.id,
+			})
+			if err != nil {
+				log.Printf("Could not render reply body: %s", err)
+				return
+			}
+
+			msg.Data = b.Bytes()
+		}
+
+		if nc.HeadersSupported() {
+			msg.Header.Set("NATS-Reply-Instance", c.id)
 		}
 
 		err = m.RespondMsg(msg)
@@ -156,7 +223,7 @@ func (c *replyCmd) reply(_ *kingpin.ParseContext) error {
 		return err
 	}
 
-	log.Printf("Listening on %q in group %q", c.subject, c.queue)
+	log.Printf("Listening on %q in group %q as instance %q", c.subject, c.queue, c.id)
 
 	ic := make(chan os.Signal, 1)
 	signal.Notify(ic, os.Interrupt)
@@ -17,6 +17,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"strings"
 
 	"gopkg.in/alecthomas/kingpin.v2"
@@ -32,13 +33,21 @@ func configureSchemaValidateCommand(schema *kingpin.CmdClause) {
 	c := &schemaValidateCmd{}
 	validate := schema.Command("validate", "Validates a JSON file against a schema").Alias("check").Action(c.validate)
 	validate.Arg("schema", "Schema ID to validate against").Required().StringVar(&c.schema)
-	validate.Arg("file", "JSON data to validate").Required().StringVar(&c.file)
+	validate.Arg("file", "JSON data to validate, STDIN is read when not given or -").StringVar(&c.file)
 	validate.Flag("json", "Produce JSON format output").BoolVar(&c.json)
 
 }
 
 func (c *schemaValidateCmd) validate(_ *kingpin.ParseContext) error {
-	file, err := ioutil.ReadFile(c.file)
+	var file []byte
+	var err error
+
+	if c.file == "" || c.file == "-" {
+		c.file = "STDIN"
+		file, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		file, err = ioutil.ReadFile(c.file)
+	}
 	if err != nil {
 		return err
 	}
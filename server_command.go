@@ -21,6 +21,7 @@ func configureServerCommand(app *kingpin.Application) {
 	srv := app.Command("server", "Server information").Alias("srv")
 	configureServerInfoCommand(srv)
 	configureServerListCommand(srv)
+	configureServerMappingCommand(srv)
 	configureServerPingCommand(srv)
 	configureServerReportCommand(srv)
 	configureServerRequestCommand(srv)
@@ -15,7 +15,9 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log"
 	"os"
 	"os/signal"
 	"regexp"
@@ -40,6 +42,43 @@ type SrvLsCmd struct {
 	reverse bool
 }
 
+// srvLsTopology is the network of a server in JSON output, routes and gateways are identified by
+// remote server ID and gateway name while leafnodes, that do not report their identity, are
+// identified by account and address
+type srvLsTopology struct {
+	Routes    []*srvLsRoute   `json:"routes"`
+	Gateways  []*srvLsGateway `json:"gateways"`
+	Leafnodes []*srvLsLeaf    `json:"leafnodes"`
+}
+
+type srvLsRoute struct {
+	ID           uint64 `json:"rid"`
+	RemoteID     string `json:"remote_id"`
+	RemoteName   string `json:"remote_name,omitempty"`
+	IP           string `json:"ip"`
+	Port         int    `json:"port"`
+	DidSolicit   bool   `json:"did_solicit"`
+	IsConfigured bool   `json:"is_configured"`
+	RTT          string `json:"rtt,omitempty"`
+}
+
+type srvLsGateway struct {
+	Name         string `json:"name"`
+	Direction    string `json:"direction"`
+	IsConfigured bool   `json:"is_configured"`
+	CID          uint64 `json:"cid,omitempty"`
+	IP           string `json:"ip,omitempty"`
+	Port         int    `json:"port,omitempty"`
+	RTT          string `json:"rtt,omitempty"`
+}
+
+type srvLsLeaf struct {
+	Account string `json:"account"`
+	IP      string `json:"ip"`
+	Port    int    `json:"port"`
+	RTT     string `json:"rtt,omitempty"`
+}
+
 type srvListCluster struct {
 	name  string
 	nodes []string
@@ -51,9 +90,25 @@ type srvListCluster struct {
 func configureServerListCommand(srv *kingpin.CmdClause) {
 	c := &SrvLsCmd{}
 
-	ls := srv.Command("list", "List known servers").Alias("ls").Action(c.list)
+	help := `List known servers
+
+In JSON output every server includes a topology key listing its routes,
+gateways and leafnode connections:
+
+   routes:    rid, remote_id, remote_name, ip, port, did_solicit,
+              is_configured, rtt
+   gateways:  name, direction (inbound or outbound), is_configured, cid,
+              ip, port, rtt
+   leafnodes: account, ip, port, rtt
+
+Routes are identified by the remote_id of the server they connect to and
+gateways by the name of the remote cluster. Leafnodes do not report their
+identity so only the account and address are known.
+`
+
+	ls := srv.Command("list", help).Alias("ls").Action(c.list)
 	ls.Arg("expect", "How many servers to expect").Uint32Var(&c.expect)
-	ls.Flag("json", "Produce JSON output").Short('j').BoolVar(&c.json)
+	ls.Flag("json", "Produce JSON output including the network topology").Short('j').BoolVar(&c.json)
 	ls.Flag("filter", "Regular expression filter on server name").Short('f').StringVar(&c.filter)
 	ls.Flag("sort", "Sort servers by a specific key (conns,subs,routes,gws,mem,cpu,slow,uptime,rtt").Default("rtt").EnumVar(&c.sort, strings.Split("conns,conn,subs,sub,routes,route,gw,mem,cpu,slow,uptime,rtt", ",")...)
 	ls.Flag("reverse", "Reverse sort servers").Short('R').BoolVar(&c.reverse)
@@ -79,7 +134,8 @@ func (c *SrvLsCmd) list(_ *kingpin.ParseContext) error {
 
 	type result struct {
 		*server.ServerStatsMsg
-		rtt time.Duration
+		Topology *srvLsTopology `json:"topology,omitempty"`
+		rtt      time.Duration
 	}
 
 	var results []*result
@@ -163,6 +219,15 @@ func (c *SrvLsCmd) list(_ *kingpin.ParseContext) error {
 	}
 
 	if c.json {
+		topology, err := c.topology(nc, len(results))
+		if err != nil {
+			return err
+		}
+
+		for _, r := range results {
+			r.Topology = topology[r.Server.ID]
+		}
+
 		printJSON(results)
 		return nil
 	}
@@ -255,3 +320,207 @@ func (c *SrvLsCmd) showClusters(cl map[string]*srvListCluster) {
 
 	fmt.Print(table.Render())
 }
+
+// topology gathers the routes, gateways and leafnodes of responding servers keyed by server ID
+func (c *SrvLsCmd) topology(nc *nats.Conn, servers int) (map[string]*srvLsTopology, error) {
+	topology := make(map[string]*srvLsTopology)
+	names := make(map[string]string)
+
+	get := func(id string) *srvLsTopology {
+		t, ok := topology[id]
+		if !ok {
+			t = &srvLsTopology{Routes: []*srvLsRoute{}, Gateways: []*srvLsGateway{}, Leafnodes: []*srvLsLeaf{}}
+			topology[id] = t
+		}
+
+		return t
+	}
+
+	err := c.eachResponse(nc, "ROUTEZ", &server.RoutezOptions{}, servers, func(si *server.ServerInfo, data json.RawMessage) error {
+		routez := &server.Routez{}
+		err := json.Unmarshal(data, routez)
+		if err != nil {
+			return err
+		}
+
+		names[si.ID] = si.Name
+		t := get(si.ID)
+		for _, r := range routez.Routes {
+			t.Routes = append(t.Routes, &srvLsRoute{
+				ID:           r.Rid,
+				RemoteID:     r.RemoteID,
+				IP:           r.IP,
+				Port:         r.Port,
+				DidSolicit:   r.DidSolicit,
+				IsConfigured: r.IsConfigured,
+				RTT:          r.RTT,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.eachResponse(nc, "GATEWAYZ", &server.GatewayzOptions{}, servers, func(si *server.ServerInfo, data json.RawMessage) error {
+		gwz := &server.Gatewayz{}
+		err := json.Unmarshal(data, gwz)
+		if err != nil {
+			return err
+		}
+
+		t := get(si.ID)
+		for name, gw := range gwz.OutboundGateways {
+			t.Gateways = append(t.Gateways, c.gateway(name, "outbound", gw))
+		}
+		for name, gws := range gwz.InboundGateways {
+			for _, gw := range gws {
+				t.Gateways = append(t.Gateways, c.gateway(name, "inbound", gw))
+			}
+		}
+
+		sort.Slice(t.Gateways, func(i, j int) bool {
+			if t.Gateways[i].Name != t.Gateways[j].Name {
+				return t.Gateways[i].Name < t.Gateways[j].Name
+			}
+			if t.Gateways[i].Direction != t.Gateways[j].Direction {
+				return t.Gateways[i].Direction > t.Gateways[j].Direction
+			}
+			return t.Gateways[i].CID < t.Gateways[j].CID
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.eachResponse(nc, "LEAFZ", &server.LeafzOptions{}, servers, func(si *server.ServerInfo, data json.RawMessage) error {
+		leafz := &server.Leafz{}
+		err := json.Unmarshal(data, leafz)
+		if err != nil {
+			return err
+		}
+
+		t := get(si.ID)
+		for _, l := range leafz.Leafs {
+			t.Leafnodes = append(t.Leafnodes, &srvLsLeaf{
+				Account: l.Account,
+				IP:      l.IP,
+				Port:    l.Port,
+				RTT:     l.RTT,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range topology {
+		for _, r := range t.Routes {
+			r.RemoteName = names[r.RemoteID]
+		}
+	}
+
+	return topology, nil
+}
+
+func (c *SrvLsCmd) gateway(name string, direction string, gw *server.RemoteGatewayz) *srvLsGateway {
+	res := &srvLsGateway{Name: name, Direction: direction, IsConfigured: gw.IsConfigured}
+	if gw.Connection != nil {
+		res.CID = gw.Connection.Cid
+		res.IP = gw.Connection.IP
+		res.Port = gw.Connection.Port
+		res.RTT = gw.Connection.RTT
+	}
+
+	return res
+}
+
+// eachResponse sends a ping request of kind to all servers and calls cb with the server and data of every response
+func (c *SrvLsCmd) eachResponse(nc *nats.Conn, kind string, req interface{}, servers int, cb func(*server.ServerInfo, json.RawMessage) error) error {
+	res, err := c.doReq(kind, req, servers, nc)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range res {
+		resp := struct {
+			Server *server.ServerInfo     `json:"server"`
+			Data   json.RawMessage        `json:"data"`
+			Error  map[string]interface{} `json:"error"`
+		}{}
+
+		err = json.Unmarshal(r, &resp)
+		if err != nil {
+			return err
+		}
+
+		if resp.Error != nil {
+			return fmt.Errorf("invalid %s response received: %v", kind, resp.Error)
+		}
+
+		if resp.Server == nil || resp.Data == nil {
+			return fmt.Errorf("invalid %s response received: %s", kind, string(r))
+		}
+
+		err = cb(resp.Server, resp.Data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *SrvLsCmd) doReq(kind string, req interface{}, waitFor int, nc *nats.Conn) ([][]byte, error) {
+	jreq, err := json.MarshalIndent(req, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	subj := fmt.Sprintf("$SYS.REQ.SERVER.PING.%s", kind)
+
+	if trace {
+		log.Printf(">>> %s: %s\n", subj, string(jreq))
+	}
+
+	var resp [][]byte
+	var mu sync.Mutex
+	ctr := 0
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	sub, err := nc.Subscribe(nats.NewInbox(), func(m *nats.Msg) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		resp = append(resp, m.Data)
+		ctr++
+
+		if ctr == waitFor {
+			cancel()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sub.AutoUnsubscribe(waitFor)
+
+	err = nc.PublishRequest(subj, sub.Subject, jreq)
+	if err != nil {
+		return nil, err
+	}
+
+	<-ctx.Done()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	return resp, nil
+}
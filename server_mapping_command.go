@@ -0,0 +1,255 @@
+// Copyright 2020 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xlab/tablewriter"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type SrvMappingCmd struct {
+	src     string
+	dests   []string
+	subject string
+}
+
+// subjectMapping is a single weighted destination of a server subject mapping
+type subjectMapping struct {
+	src    []string
+	dest   []string
+	weight int
+}
+
+func configureServerMappingCommand(srv *kingpin.CmdClause) {
+	c := &SrvMappingCmd{}
+
+	help := `Test the result of a server subject mapping
+
+Computes the subject a message published on --subject is mapped to by a
+mapping from SOURCE to one or more DESTINATION subjects without contacting
+the server. Wildcards in the source are referenced as $1, $2 etc in the
+destinations and weights are set using DESTINATION=WEIGHT.
+
+   nats server mapping 'orders.*.*' 'orders.$2.$1' --subject orders.new.eu
+   nats server mapping 'svc.>' 'svc.v1.>=80' 'svc.v2.>=20' --subject svc.ping
+
+When the weights do not add up to 100 the remainder is sent to the
+published subject unchanged as the server does.
+`
+
+	mapping := srv.Command("mapping", help).Action(c.mappingAction)
+	mapping.Arg("source", "The subject the mapping applies to").Required().StringVar(&c.src)
+	mapping.Arg("destination", "Subjects to map to with optional weights as DESTINATION=WEIGHT").Required().StringsVar(&c.dests)
+	mapping.Flag("subject", "The published subject to map").Required().StringVar(&c.subject)
+}
+
+func (c *SrvMappingCmd) mappingAction(_ *kingpin.ParseContext) error {
+	mappings, err := parseSubjectMappings(c.src, c.dests)
+	if err != nil {
+		return err
+	}
+
+	if !isLiteralSubject(c.subject) {
+		return fmt.Errorf("%q is not a valid subject to publish to", c.subject)
+	}
+
+	table := tablewriter.CreateTable()
+	table.AddTitle(fmt.Sprintf("Mapping of %s", c.subject))
+	table.AddHeaders("Destination", "Weight", "Mapped Subject")
+
+	for _, m := range mappings {
+		mapped, ok := m.Apply(strings.Split(c.subject, "."))
+		if !ok {
+			return fmt.Errorf("subject %q does not match the mapping source %q", c.subject, c.src)
+		}
+
+		dest := strings.Join(m.dest, ".")
+		if m.dest == nil {
+			dest = "unmapped"
+		}
+
+		table.AddRow(dest, fmt.Sprintf("%d%%", m.weight), mapped)
+	}
+
+	fmt.Println(table.Render())
+
+	return nil
+}
+
+// parseSubjectMappings validates the mapping of src to the weighted destinations like the server does, adding the
+// unchanged source for any weight not assigned to a destination
+func parseSubjectMappings(src string, dests []string) ([]*subjectMapping, error) {
+	stoks := strings.Split(src, ".")
+	if !isValidSubject(stoks) {
+		return nil, fmt.Errorf("invalid source subject %q", src)
+	}
+
+	pwcs := 0
+	for _, t := range stoks {
+		if t == "*" {
+			pwcs++
+		}
+	}
+	fwc := stoks[len(stoks)-1] == ">"
+
+	var mappings []*subjectMapping
+	seen := make(map[string]bool)
+	total := 0
+
+	for _, d := range dests {
+		m := &subjectMapping{src: stoks, weight: 100}
+
+		subj := d
+		if idx := strings.LastIndex(d, "="); idx >= 0 {
+			subj = d[:idx]
+			w, err := strconv.Atoi(strings.TrimSuffix(d[idx+1:], "%"))
+			if err != nil || w < 0 || w > 100 {
+				return nil, fmt.Errorf("invalid weight in %q, weights should be 0 to 100", d)
+			}
+			m.weight = w
+		}
+
+		if seen[subj] {
+			return nil, fmt.Errorf("duplicate destination %q", subj)
+		}
+		seen[subj] = true
+
+		m.dest = strings.Split(subj, ".")
+		if !isValidSubject(m.dest) || (m.dest[len(m.dest)-1] == ">") != fwc {
+			return nil, fmt.Errorf("invalid destination subject %q", subj)
+		}
+
+		holders := 0
+		for _, t := range m.dest {
+			if t == "*" {
+				return nil, fmt.Errorf("destination %q can not have * wildcards, use $1, $2 etc to reference wildcards in the source", subj)
+			}
+
+			if idx := placeHolderIndex(t); idx > 0 {
+				if idx > pwcs {
+					return nil, fmt.Errorf("destination %q references wildcard $%d but the source has %d", subj, idx, pwcs)
+				}
+				holders++
+			}
+		}
+
+		if pwcs > 0 && holders != pwcs {
+			return nil, fmt.Errorf("destination %q must reference every wildcard in the source", subj)
+		}
+
+		total += m.weight
+		if total > 100 {
+			return nil, fmt.Errorf("total weight of all destinations can not be more than 100")
+		}
+
+		mappings = append(mappings, m)
+	}
+
+	if total < 100 && !seen[src] {
+		mappings = append(mappings, &subjectMapping{src: stoks, weight: 100 - total})
+	}
+
+	return mappings, nil
+}
+
+// Apply maps the published subject tokens to the destination, a mapping without a destination keeps the subject.
+// False is returned when the subject does not match the source
+func (m *subjectMapping) Apply(subject []string) (string, bool) {
+	var wildcards []string
+
+	for i, t := range m.src {
+		switch {
+		case t == ">":
+			if i >= len(subject) {
+				return "", false
+			}
+		case i >= len(subject):
+			return "", false
+		case t == "*":
+			wildcards = append(wildcards, subject[i])
+		case t != subject[i]:
+			return "", false
+		}
+	}
+
+	fwc := m.src[len(m.src)-1] == ">"
+	if !fwc && len(subject) != len(m.src) {
+		return "", false
+	}
+
+	if m.dest == nil {
+		return strings.Join(subject, "."), true
+	}
+
+	var mapped []string
+	for _, t := range m.dest {
+		switch idx := placeHolderIndex(t); {
+		case idx > 0:
+			mapped = append(mapped, wildcards[idx-1])
+		case t == ">":
+			mapped = append(mapped, subject[len(m.src)-1:]...)
+		default:
+			mapped = append(mapped, t)
+		}
+	}
+
+	return strings.Join(mapped, "."), true
+}
+
+// placeHolderIndex is N for a $N destination token, -1 for other tokens
+func placeHolderIndex(token string) int {
+	if len(token) < 2 || token[0] != '$' {
+		return -1
+	}
+
+	idx, err := strconv.Atoi(token[1:])
+	if err != nil {
+		return -1
+	}
+
+	return idx
+}
+
+func isValidSubject(tokens []string) bool {
+	for i, t := range tokens {
+		if t == "" || strings.ContainsAny(t, " \t\r\n") {
+			return false
+		}
+
+		if t == ">" && i != len(tokens)-1 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isLiteralSubject(subject string) bool {
+	tokens := strings.Split(subject, ".")
+	if !isValidSubject(tokens) {
+		return false
+	}
+
+	for _, t := range tokens {
+		if t == "*" || t == ">" {
+			return false
+		}
+	}
+
+	return true
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/guptarohit/asciigraph"
 	"github.com/nats-io/nats-server/v2/server"
 	"github.com/nats-io/nats.go"
+	"github.com/xlab/tablewriter"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
@@ -33,6 +34,24 @@ type SrvPingCmd struct {
 	expect uint32
 	graph  bool
 	showId bool
+	json   bool
+	table  bool
+}
+
+type srvPingResult struct {
+	Name      string        `json:"name"`
+	ID        string        `json:"id"`
+	Cluster   string        `json:"cluster,omitempty"`
+	JetStream bool          `json:"jetstream"`
+	RTT       time.Duration `json:"rtt"`
+}
+
+type srvPingSummary struct {
+	Servers []*srvPingResult `json:"servers"`
+	Missing uint32           `json:"missing,omitempty"`
+	Min     time.Duration    `json:"min"`
+	Avg     time.Duration    `json:"avg"`
+	Max     time.Duration    `json:"max"`
 }
 
 func configureServerPingCommand(srv *kingpin.CmdClause) {
@@ -42,6 +61,8 @@ func configureServerPingCommand(srv *kingpin.CmdClause) {
 	ls.Arg("expect", "How many servers to expect").Uint32Var(&c.expect)
 	ls.Flag("graph", "Produce a response distribution graph").BoolVar(&c.graph)
 	ls.Flag("id", "Include the Server ID in the output").BoolVar(&c.showId)
+	ls.Flag("json", "Produce a JSON summary once all servers responded").Short('j').BoolVar(&c.json)
+	ls.Flag("table", "Produce a table of servers sorted by RTT once all servers responded").BoolVar(&c.table)
 }
 
 func (c *SrvPingCmd) ping(_ *kingpin.ParseContext) error {
@@ -62,6 +83,7 @@ func (c *SrvPingCmd) ping(_ *kingpin.ParseContext) error {
 	mu := &sync.Mutex{}
 	start := time.Now()
 	times := []float64{}
+	results := []*srvPingResult{}
 
 	sub, err := ec.Subscribe(nc.NewRespInbox(), func(ssm *server.ServerStatsMsg) {
 		last := atomic.AddUint32(&seen, 1)
@@ -72,10 +94,20 @@ func (c *SrvPingCmd) ping(_ *kingpin.ParseContext) error {
 		since := time.Since(start)
 		rtt := since.Milliseconds()
 		times = append(times, float64(rtt))
-
-		if c.showId {
+		results = append(results, &srvPingResult{
+			Name:      ssm.Server.Name,
+			ID:        ssm.Server.ID,
+			Cluster:   ssm.Server.Cluster,
+			JetStream: ssm.Server.JetStream,
+			RTT:       since,
+		})
+
+		// summaries are shown once the ping window closes
+		switch {
+		case c.json, c.table:
+		case c.showId:
 			fmt.Printf("%s %-60s rtt=%s\n", ssm.Server.ID, ssm.Server.Name, since)
-		} else {
+		default:
 			fmt.Printf("%-60s rtt=%s\n", ssm.Server.Name, since)
 		}
 
@@ -103,6 +135,23 @@ func (c *SrvPingCmd) ping(_ *kingpin.ParseContext) error {
 
 	sub.Drain()
 
+	if c.json || c.table {
+		mu.Lock()
+		defer mu.Unlock()
+
+		err = c.summarizeResults(results, atomic.LoadUint32(&seen))
+		if err != nil {
+			return err
+		}
+
+		// scripts rely on the exit code to detect missing servers
+		if seen := atomic.LoadUint32(&seen); c.expect != 0 && c.expect != seen {
+			return fmt.Errorf("expected %d servers got %d", c.expect, seen)
+		}
+
+		return nil
+	}
+
 	c.summarize(times)
 
 	if c.expect != 0 && c.expect != seen {
@@ -112,6 +161,62 @@ func (c *SrvPingCmd) ping(_ *kingpin.ParseContext) error {
 	return nil
 }
 
+func (c *SrvPingCmd) summarizeResults(results []*srvPingResult, seen uint32) error {
+	sort.Slice(results, func(i, j int) bool { return results[i].RTT > results[j].RTT })
+
+	summary := &srvPingSummary{Servers: results}
+	if c.expect > seen {
+		summary.Missing = c.expect - seen
+	}
+
+	if len(results) > 0 {
+		var total time.Duration
+		for _, r := range results {
+			total += r.RTT
+		}
+
+		summary.Max = results[0].RTT
+		summary.Min = results[len(results)-1].RTT
+		summary.Avg = total / time.Duration(len(results))
+	}
+
+	if c.json {
+		printJSON(summary)
+		return nil
+	}
+
+	table := tablewriter.CreateTable()
+	table.AddTitle("Server Ping")
+	if c.showId {
+		table.AddHeaders("Name", "ID", "Cluster", "JetStream", "RTT")
+	} else {
+		table.AddHeaders("Name", "Cluster", "JetStream", "RTT")
+	}
+
+	for _, r := range results {
+		if c.showId {
+			table.AddRow(r.Name, r.ID, r.Cluster, r.JetStream, r.RTT.Round(time.Microsecond))
+		} else {
+			table.AddRow(r.Name, r.Cluster, r.JetStream, r.RTT.Round(time.Microsecond))
+		}
+	}
+
+	fmt.Print(table.Render())
+	fmt.Println()
+
+	if len(results) == 0 {
+		fmt.Println("no responses received")
+	} else {
+		fmt.Printf("%d replies max: %v min: %v avg: %v\n", len(results), summary.Max.Round(time.Microsecond), summary.Min.Round(time.Microsecond), summary.Avg.Round(time.Microsecond))
+	}
+
+	if summary.Missing > 0 {
+		fmt.Printf("\nMissing %d server(s)\n", summary.Missing)
+	}
+
+	return nil
+}
+
 func (c *SrvPingCmd) summarize(times []float64) {
 	fmt.Println()
 	fmt.Println("---- ping statistics ----")
@@ -49,6 +49,12 @@ type srvReportAccountInfo struct {
 	Subs        int                `json:"subscriptions"`
 }
 
+type srvReportLeafInfo struct {
+	Server  string `json:"server"`
+	Cluster string `json:"cluster,omitempty"`
+	*server.LeafInfo
+}
+
 func configureServerReportCommand(srv *kingpin.CmdClause) {
 	c := &SrvReportCmd{}
 
@@ -67,6 +73,121 @@ func configureServerReportCommand(srv *kingpin.CmdClause) {
 	acct.Arg("limit", "Limit the responses to a certain amount of servers").Default("1024").IntVar(&c.waitFor)
 	acct.Flag("sort", "Sort by a specific property (in-bytes,out-bytes,in-msgs,out-msgs,conns,subs,uptime,cid)").Default("subs").EnumVar(&c.sort, "in-bytes", "out-bytes", "in-msgs", "out-msgs", "conns", "subs", "uptime", "cid")
 	acct.Flag("top", "Limit results to the top results").IntVar(&c.topk)
+
+	leafs := report.Command("leafnodes", "Report on leafnode connections").Alias("leafs").Alias("leaf").Alias("leafz").Action(c.reportLeafs)
+	leafs.Arg("limit", "Limit the responses to a certain amount of servers").Default("1024").IntVar(&c.waitFor)
+	leafs.Flag("account", "Limit report to a specific account").StringVar(&c.account)
+	leafs.Flag("sort", "Sort by a specific property (in-bytes,out-bytes,in-msgs,out-msgs,subs,account,server)").Default("subs").EnumVar(&c.sort, "in-bytes", "out-bytes", "in-msgs", "out-msgs", "subs", "account", "server")
+	leafs.Flag("top", "Limit results to the top results").IntVar(&c.topk)
+}
+
+func (c *SrvReportCmd) reportLeafs(_ *kingpin.ParseContext) error {
+	nc, _, err := prepareHelper("", natsOpts()...)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.doReq(&server.LeafzOptions{Account: c.account}, "$SYS.REQ.SERVER.PING.LEAFZ", nc)
+	if err != nil {
+		return err
+	}
+
+	if len(res) == 0 {
+		return fmt.Errorf("did not get results from any servers")
+	}
+
+	leafs := []*srvReportLeafInfo{}
+
+	for _, r := range res {
+		resp := struct {
+			Server *server.ServerInfo     `json:"server"`
+			Data   *server.Leafz          `json:"data"`
+			Error  map[string]interface{} `json:"error"`
+		}{}
+
+		err = json.Unmarshal(r, &resp)
+		if err != nil {
+			return err
+		}
+
+		if resp.Error != nil {
+			return fmt.Errorf("invalid response received: %#v", resp.Error)
+		}
+
+		if resp.Server == nil || resp.Data == nil {
+			return fmt.Errorf("no data received in response: %s", string(r))
+		}
+
+		for _, l := range resp.Data.Leafs {
+			leafs = append(leafs, &srvReportLeafInfo{Server: resp.Server.Name, Cluster: resp.Server.Cluster, LeafInfo: l})
+		}
+	}
+
+	c.sortLeafs(leafs)
+
+	report := leafs
+	if c.topk > 0 && c.topk <= len(leafs) {
+		report = leafs[len(leafs)-c.topk:]
+	}
+
+	if c.json {
+		printJSON(report)
+		return nil
+	}
+
+	c.renderLeafs(report)
+
+	return nil
+}
+
+func (c *SrvReportCmd) sortLeafs(leafs []*srvReportLeafInfo) {
+	sort.Slice(leafs, func(i int, j int) bool {
+		switch c.sort {
+		case "in-bytes":
+			return c.boolReverse(leafs[i].InBytes < leafs[j].InBytes)
+		case "out-bytes":
+			return c.boolReverse(leafs[i].OutBytes < leafs[j].OutBytes)
+		case "in-msgs":
+			return c.boolReverse(leafs[i].InMsgs < leafs[j].InMsgs)
+		case "out-msgs":
+			return c.boolReverse(leafs[i].OutMsgs < leafs[j].OutMsgs)
+		case "account":
+			return c.boolReverse(leafs[i].Account < leafs[j].Account)
+		case "server":
+			return c.boolReverse(leafs[i].Server < leafs[j].Server)
+		default:
+			return c.boolReverse(leafs[i].NumSubs < leafs[j].NumSubs)
+		}
+	})
+}
+
+func (c *SrvReportCmd) renderLeafs(report []*srvReportLeafInfo) {
+	table := tablewriter.CreateTable()
+	table.AddTitle(fmt.Sprintf("%d Leafnode Connections Overview", len(report)))
+	table.AddHeaders("Server", "Cluster", "Account", "Address", "RTT", "In Msgs", "Out Msgs", "In Bytes", "Out Bytes", "Subs")
+
+	var oMsgs int64
+	var iMsgs int64
+	var oBytes int64
+	var iBytes int64
+	var subs uint32
+
+	for _, info := range report {
+		oMsgs += info.OutMsgs
+		iMsgs += info.InMsgs
+		oBytes += info.OutBytes
+		iBytes += info.InBytes
+		subs += info.NumSubs
+
+		table.AddRow(info.Server, info.Cluster, info.Account, fmt.Sprintf("%s:%d", info.IP, info.Port), info.RTT, humanize.Comma(info.InMsgs), humanize.Comma(info.OutMsgs), humanize.IBytes(uint64(info.InBytes)), humanize.IBytes(uint64(info.OutBytes)), humanize.Comma(int64(info.NumSubs)))
+	}
+
+	if len(report) > 1 {
+		table.AddSeparator()
+		table.AddRow("", "", "", "", "", humanize.Comma(iMsgs), humanize.Comma(oMsgs), humanize.IBytes(uint64(iBytes)), humanize.IBytes(uint64(oBytes)), humanize.Comma(int64(subs)))
+	}
+
+	fmt.Print(table.Render())
 }
 
 func (c *SrvReportCmd) reportAccount(_ *kingpin.ParseContext) error {
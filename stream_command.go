@@ -14,9 +14,12 @@
 package main
 
 import (
+	"archive/tar"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -36,6 +39,7 @@ import (
 	"github.com/nats-io/jsm.go/api"
 	"github.com/nats-io/nats.go"
 	"github.com/xlab/tablewriter"
+	"golang.org/x/crypto/ssh/terminal"
 	"gopkg.in/alecthomas/kingpin.v2"
 
 	"github.com/nats-io/jsm.go"
@@ -64,6 +68,7 @@ type streamCmd struct {
 	reportSortName      bool
 	reportSortStorage   bool
 	reportRaw           bool
+	reportSort          string
 	maxStreams          int
 	discardPolicy       string
 	validateOnly        bool
@@ -71,9 +76,17 @@ type streamCmd struct {
 	showProgress        bool
 	healthCheck         bool
 	dupeWindow          string
+	replicas            int
+	copyData            bool
+	findIdle            string
+	findEmpty           bool
+	gapsScan            bool
+	msgTime             string
+	remaps              []string
 
 	vwStartId    int
 	vwStartDelta time.Duration
+	vwStartTime  string
 	vwPageSize   int
 	vwRaw        bool
 
@@ -95,27 +108,29 @@ func configureStreamCommand(app *kingpin.Application) {
 		f.Flag("discard", "Defines the discard policy (new, old)").EnumVar(&c.discardPolicy, "new", "old")
 		f.Flag("max-msg-size", "Maximum size any 1 message may be").Int32Var(&c.maxMsgSize)
 		f.Flag("json", "Produce JSON output").Short('j').BoolVar(&c.json)
-		f.Flag("dupe-window", "Window size for duplicate tracking").Default("").StringVar(&c.dupeWindow)
+		f.Flag("dupe-window", "Window size for duplicate tracking using the Nats-Msg-Id header").Default("").StringVar(&c.dupeWindow)
+		f.Flag("replicas", "When clustered, how many replicas of the data to create").IntVar(&c.replicas)
 	}
 
 	str := app.Command("stream", "JetStream Stream management").Alias("str").Alias("st").Alias("ms").Alias("s")
 
 	strAdd := str.Command("add", "Create a new Stream").Alias("create").Alias("new").Action(c.addAction)
 	strAdd.Arg("stream", "Stream name").StringVar(&c.stream)
-	strAdd.Flag("config", "JSON file to read configuration from").ExistingFileVar(&c.inputFile)
+	strAdd.Flag("config", "JSON or YAML file to read configuration from").ExistingFileVar(&c.inputFile)
 	strAdd.Flag("validate", "Only validates the configuration against the official Schema").BoolVar(&c.validateOnly)
 	strAdd.Flag("output", "Save configuration instead of creating").PlaceHolder("FILE").StringVar(&c.outFile)
 	addCreateFlags(strAdd)
 
 	strEdit := str.Command("edit", "Edits an existing stream").Action(c.editAction)
 	strEdit.Arg("stream", "Stream to retrieve edit").StringVar(&c.stream)
-	strEdit.Flag("config", "JSON file to read configuration from").ExistingFileVar(&c.inputFile)
+	strEdit.Flag("config", "JSON or YAML file to read configuration from").ExistingFileVar(&c.inputFile)
 	strEdit.Flag("force", "Force edit without prompting").Short('f').BoolVar(&c.force)
 	addCreateFlags(strEdit)
 
 	strInfo := str.Command("info", "Stream information").Alias("nfo").Alias("i").Action(c.infoAction)
 	strInfo.Arg("stream", "Stream to retrieve information for").StringVar(&c.stream)
 	strInfo.Flag("json", "Produce JSON output").Short('j').BoolVar(&c.json)
+	strInfo.Flag("config-out", "Saves the Stream configuration to a file that can be used with --config").PlaceHolder("FILE").StringVar(&c.outFile)
 
 	strLs := str.Command("ls", "List all known Streams").Alias("list").Alias("l").Action(c.lsAction)
 	strLs.Flag("subject", "Filters Streams by those with interest matching a subject or wildcard").StringVar(&c.filterSubject)
@@ -130,15 +145,29 @@ func configureStreamCommand(app *kingpin.Application) {
 	strPurge.Flag("json", "Produce JSON output").Short('j').BoolVar(&c.json)
 	strPurge.Flag("force", "Force removal without prompting").Short('f').BoolVar(&c.force)
 
+	strFind := str.Command("find", "Finds Streams matching all of the given criteria").Action(c.findAction)
+	strFind.Flag("idle", "Streams that did not receive messages for this duration").PlaceHolder("DURATION").StringVar(&c.findIdle)
+	strFind.Flag("empty", "Streams without any messages").BoolVar(&c.findEmpty)
+	strFind.Flag("replicas", "Streams with this many replicas").IntVar(&c.replicas)
+	strFind.Flag("storage", "Streams using this storage backend (file, memory)").EnumVar(&c.storage, "file", "f", "memory", "m")
+	strFind.Flag("subject", "Streams with subjects overlapping this subject or wildcard").StringVar(&c.filterSubject)
+
+	strGaps := str.Command("gaps", "Reports ranges of sequences missing from a Stream").Action(c.gapsAction)
+	strGaps.Arg("stream", "Stream name").StringVar(&c.stream)
+	strGaps.Flag("scan", "Reads every message between the first and last sequence to locate gaps caused by deleted messages").BoolVar(&c.gapsScan)
+	strGaps.Flag("json", "Produce JSON output").Short('j').BoolVar(&c.json)
+
 	strCopy := str.Command("copy", "Creates a new Stream based on the configuration of another").Alias("cp").Action(c.cpAction)
 	strCopy.Arg("source", "Source Stream to copy").Required().StringVar(&c.stream)
 	strCopy.Arg("destination", "New Stream to create").Required().StringVar(&c.destination)
+	strCopy.Flag("data", "Copies the messages to the destination, requires a single subject not used by the source").BoolVar(&c.copyData)
 	addCreateFlags(strCopy)
 
 	strGet := str.Command("get", "Retrieves a specific message from a Stream").Action(c.getAction)
 	strGet.Arg("stream", "Stream name").StringVar(&c.stream)
 	strGet.Arg("id", "Message ID to retrieve").Int64Var(&c.msgID)
 	strGet.Flag("json", "Produce JSON output").Short('j').BoolVar(&c.json)
+	strGet.Flag("time", "Retrieves the first message stored at or after this RFC3339 time").PlaceHolder("TIME").StringVar(&c.msgTime)
 
 	strRmMsg := str.Command("rmm", "Securely removes an individual message from a Stream").Action(c.rmMsgAction)
 	strRmMsg.Arg("stream", "Stream name").StringVar(&c.stream)
@@ -150,6 +179,7 @@ func configureStreamCommand(app *kingpin.Application) {
 	strView.Arg("size", "Page size").Default("10").IntVar(&c.vwPageSize)
 	strView.Flag("id", "Start at a specific message ID").IntVar(&c.vwStartId)
 	strView.Flag("since", "Start at a time delta").DurationVar(&c.vwStartDelta)
+	strView.Flag("since-time", "Start at the first message stored at or after this RFC3339 time").PlaceHolder("TIME").StringVar(&c.vwStartTime)
 	strView.Flag("raw", "Show the raw data received").BoolVar(&c.vwRaw)
 
 	strBackup := str.Command("backup", "Backs up a Stream over the NATS network").Action(c.backupAction)
@@ -165,11 +195,13 @@ func configureStreamCommand(app *kingpin.Application) {
 	strReport.Flag("name", "Sort by Stream name").Short('n').BoolVar(&c.reportSortName)
 	strReport.Flag("storage", "Sort by Storage type").Short('t').BoolVar(&c.reportSortStorage)
 	strReport.Flag("raw", "Show un-formatted numbers").Short('r').BoolVar(&c.reportRaw)
+	strReport.Flag("sort", "Sort by a specific property (messages, bytes, consumers, name, storage)").Default("bytes").EnumVar(&c.reportSort, "messages", "msgs", "bytes", "consumers", "name", "storage")
 
 	strRestore := str.Command("restore", "Restore a Stream over the NATS network").Action(c.restoreAction)
 	strRestore.Arg("stream", "The name of the Stream to restore").Required().StringVar(&c.stream)
 	strRestore.Arg("file", "The file holding the backup to restore").Required().ExistingFileVar(&c.backupFile)
 	strRestore.Flag("progress", "Enables or disables progress reporting using a progress bar").Default("true").BoolVar(&c.showProgress)
+	strRestore.Flag("remap", "Rewrites Stream subjects matching OLD to NEW before restoring, wildcards are kept").PlaceHolder("OLD:NEW").StringsVar(&c.remaps)
 
 	strTemplate := str.Command("template", "Manages Stream Templates").Alias("templ").Alias("t")
 
@@ -207,6 +239,14 @@ func (c *streamCmd) viewAction(_ *kingpin.ParseContext) error {
 	}
 
 	switch {
+	case c.vwStartTime != "":
+		ts, err := time.Parse(time.RFC3339, c.vwStartTime)
+		kingpin.FatalIfError(err, "invalid time, expected RFC3339 format like 2006-01-02T15:04:05Z")
+
+		seq, err := c.sequenceForTime(ts)
+		kingpin.FatalIfError(err, "could not resolve a message in %s for %s", c.stream, c.vwStartTime)
+
+		pops = append(pops, jsm.PagerStartId(int(seq)))
 	case c.vwStartDelta > 0:
 		pops = append(pops, jsm.PagerStartDelta(c.vwStartDelta))
 	case c.vwStartId > 0:
@@ -274,8 +314,7 @@ func (c *streamCmd) viewAction(_ *kingpin.ParseContext) error {
 		}
 
 		if last {
-			next := false
-			survey.AskOne(&survey.Confirm{Message: "Next Page?", Default: true}, &next)
+			next, _ := askConfirmation("Next Page?", true)
 			if !next {
 				return nil
 			}
@@ -293,15 +332,30 @@ func (c *streamCmd) restoreAction(_ *kingpin.ParseContext) error {
 		kingpin.Fatalf("Stream %q already exist", c.stream)
 	}
 
+	if len(c.remaps) > 0 {
+		remapped, err := c.remapBackup(mgr)
+		kingpin.FatalIfError(err, "could not remap subjects")
+		defer os.Remove(remapped)
+
+		c.backupFile = remapped
+	}
+
+	nfo, err := os.Stat(c.backupFile)
+	kingpin.FatalIfError(err, "could not read backup")
+
 	var progress *uiprogress.Bar
-	var bps uint64
+	var bps, sent uint64
+	total := uint64(nfo.Size())
 
 	cb := func(p jsm.RestoreProgress) {
 		bps = p.BytesPerSecond()
+		sent = p.BytesSent()
 
 		if progress == nil {
 			progress = uiprogress.AddBar(p.ChunksToSend()).AppendCompleted().PrependFunc(func(b *uiprogress.Bar) string {
 				return humanize.IBytes(bps) + "/s"
+			}).AppendFunc(func(b *uiprogress.Bar) string {
+				return transferProgress(sent, total, bps)
 			})
 		}
 
@@ -310,6 +364,10 @@ func (c *streamCmd) restoreAction(_ *kingpin.ParseContext) error {
 
 	var opts []jsm.SnapshotOption
 
+	if c.showProgress && !terminal.IsTerminal(int(os.Stdout.Fd())) {
+		c.showProgress = false
+	}
+
 	if c.showProgress {
 		uiprogress.Start()
 		opts = append(opts, jsm.RestoreNotify(cb))
@@ -338,6 +396,178 @@ func (c *streamCmd) restoreAction(_ *kingpin.ParseContext) error {
 	return nil
 }
 
+// remapBackup writes a copy of the backup with the Stream subjects rewritten according to the remaps, the caller should remove the copy
+func (c *streamCmd) remapBackup(mgr *jsm.Manager) (string, error) {
+	var remaps []*subjectRemap
+	for _, r := range c.remaps {
+		remap, err := parseSubjectRemap(r)
+		if err != nil {
+			return "", err
+		}
+		remaps = append(remaps, remap)
+	}
+
+	inf, err := os.Open(c.backupFile)
+	if err != nil {
+		return "", err
+	}
+	defer inf.Close()
+
+	gzr, err := gzip.NewReader(inf)
+	if err != nil {
+		return "", err
+	}
+	defer gzr.Close()
+
+	outf, err := ioutil.TempFile("", "stream-restore-")
+	if err != nil {
+		return "", err
+	}
+	defer outf.Close()
+
+	gzw := gzip.NewWriter(outf)
+	tw := tar.NewWriter(gzw)
+	tr := tar.NewReader(gzr)
+	found := false
+
+	fail := func(err error) (string, error) {
+		outf.Close()
+		os.Remove(outf.Name())
+		return "", err
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(err)
+		}
+
+		if hdr.Name != "meta.inf" {
+			err = tw.WriteHeader(hdr)
+			if err != nil {
+				return fail(err)
+			}
+
+			_, err = io.Copy(tw, tr)
+			if err != nil {
+				return fail(err)
+			}
+
+			continue
+		}
+
+		found = true
+
+		meta, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return fail(err)
+		}
+
+		meta, err = c.remapStreamMeta(mgr, meta, remaps)
+		if err != nil {
+			return fail(err)
+		}
+
+		hdr.Size = int64(len(meta))
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return fail(err)
+		}
+
+		_, err = tw.Write(meta)
+		if err != nil {
+			return fail(err)
+		}
+	}
+
+	if !found {
+		return fail(fmt.Errorf("backup does not contain a Stream configuration"))
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return fail(err)
+	}
+
+	err = gzw.Close()
+	if err != nil {
+		return fail(err)
+	}
+
+	return outf.Name(), nil
+}
+
+// remapStreamMeta rewrites the subjects in a backed up Stream configuration and ensures they do not overlap each other or other Streams
+func (c *streamCmd) remapStreamMeta(mgr *jsm.Manager, meta []byte, remaps []*subjectRemap) ([]byte, error) {
+	// all other properties are kept as is so nothing the server wrote is lost
+	cfg := map[string]json.RawMessage{}
+	err := json.Unmarshal(meta, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var subjects []string
+	err = json.Unmarshal(cfg["subjects"], &subjects)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subjects in backup: %s", err)
+	}
+
+	for i, subject := range subjects {
+		for _, remap := range remaps {
+			remapped, ok := remap.Apply(subject)
+			if !ok {
+				continue
+			}
+
+			fmt.Printf("Remapping subject %s to %s\n", subject, remapped)
+			subjects[i] = remapped
+			break
+		}
+	}
+
+	for i := 0; i < len(subjects); i++ {
+		for j := i + 1; j < len(subjects); j++ {
+			if subjectsCollide(subjects[i], subjects[j]) {
+				return nil, fmt.Errorf("remapped subjects %s and %s overlap", subjects[i], subjects[j])
+			}
+		}
+	}
+
+	var overlap error
+	err = mgr.EachStream(func(s *jsm.Stream) {
+		if overlap != nil {
+			return
+		}
+
+		for _, existing := range s.Subjects() {
+			for _, subject := range subjects {
+				if subjectsCollide(existing, subject) {
+					overlap = fmt.Errorf("remapped subject %s overlaps with subject %s of Stream %s", subject, existing, s.Name())
+					return
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if overlap != nil {
+		return nil, overlap
+	}
+
+	cfg["subjects"], err = json.Marshal(subjects)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Println()
+
+	return json.MarshalIndent(cfg, "", "  ")
+}
+
 func (c *streamCmd) backupAction(_ *kingpin.ParseContext) error {
 	_, mgr, err := prepareHelper("", natsOpts()...)
 	kingpin.FatalIfError(err, "setup failed")
@@ -345,11 +575,16 @@ func (c *streamCmd) backupAction(_ *kingpin.ParseContext) error {
 	stream, err := mgr.LoadStream(c.stream)
 	kingpin.FatalIfError(err, "could not load stream")
 
+	state, err := stream.State()
+	kingpin.FatalIfError(err, "could not load stream state")
+
 	first := true
 	inprogress := true
 	pmu := sync.Mutex{}
 	var progress *uiprogress.Bar
-	var bps uint64
+	var bps, received uint64
+	// block sizes are limits not the real size of the blocks so the stream state is the best estimate available
+	total := state.Bytes
 
 	cb := func(p jsm.SnapshotProgress) {
 		if first {
@@ -367,10 +602,21 @@ func (c *streamCmd) backupAction(_ *kingpin.ParseContext) error {
 		}
 
 		bps = p.BytesPerSecond()
+		received = p.BlockBytesReceived()
+		if received > total {
+			received = total
+		}
 
 		if progress == nil {
 			progress = uiprogress.AddBar(p.BlocksExpected() * p.BlockSize()).AppendCompleted().PrependFunc(func(b *uiprogress.Bar) string {
 				return humanize.IBytes(bps) + "/s"
+			}).AppendFunc(func(b *uiprogress.Bar) string {
+				msgs := state.Msgs
+				if total > 0 && received < total {
+					msgs = uint64(float64(state.Msgs) * float64(received) / float64(total))
+				}
+
+				return fmt.Sprintf("%s ~%s msgs", transferProgress(received, total, bps), humanize.Comma(int64(msgs)))
 			})
 		}
 
@@ -391,6 +637,10 @@ func (c *streamCmd) backupAction(_ *kingpin.ParseContext) error {
 		jsm.SnapshotConsumers(),
 	}
 
+	if c.showProgress && !terminal.IsTerminal(int(os.Stdout.Fd())) {
+		c.showProgress = false
+	}
+
 	if c.showProgress {
 		uiprogress.Start()
 		opts = append(opts, jsm.SnapshotNotify(cb))
@@ -418,6 +668,19 @@ func (c *streamCmd) backupAction(_ *kingpin.ParseContext) error {
 	return nil
 }
 
+// transferProgress describes how much of a transfer of total bytes is done and how long the rest takes at bps
+func transferProgress(done uint64, total uint64, bps uint64) string {
+	eta := "unknown"
+	switch {
+	case done >= total:
+		eta = "0s"
+	case bps > 0:
+		eta = humanizeDuration(time.Duration(float64(total-done) / float64(bps) * float64(time.Second)))
+	}
+
+	return fmt.Sprintf("%s / %s ETA %s", humanize.IBytes(done), humanize.IBytes(total), eta)
+}
+
 func (c *streamCmd) streamTemplateRm(_ *kingpin.ParseContext) error {
 	_, mgr, err := prepareHelper("", natsOpts()...)
 	kingpin.FatalIfError(err, "setup failed")
@@ -447,7 +710,7 @@ func (c *streamCmd) streamTemplateAdd(pc *kingpin.ParseContext) (err error) {
 	cfg := c.prepareConfig()
 
 	if c.maxStreams == -1 {
-		err = survey.AskOne(&survey.Input{
+		err = askOne(&survey.Input{
 			Message: "Maximum Streams",
 		}, &c.maxStreams, survey.WithValidator(survey.Required))
 		kingpin.FatalIfError(err, "invalid input")
@@ -571,17 +834,42 @@ func (c *streamCmd) reportAction(_ *kingpin.ParseContext) error {
 		return nil
 	}
 
-	if c.reportSortConsumers {
-		sort.Slice(stats, func(i, j int) bool { return stats[i].Consumers < stats[j].Consumers })
-	} else if c.reportSortMsgs {
-		sort.Slice(stats, func(i, j int) bool { return stats[i].Msgs < stats[j].Msgs })
-	} else if c.reportSortName {
-		sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
-	} else if c.reportSortStorage {
-		sort.Slice(stats, func(i, j int) bool { return stats[i].Storage < stats[j].Storage })
-	} else {
-		sort.Slice(stats, func(i, j int) bool { return stats[i].Bytes < stats[j].Bytes })
-	}
+	switch {
+	case c.reportSortConsumers:
+		c.reportSort = "consumers"
+	case c.reportSortMsgs:
+		c.reportSort = "messages"
+	case c.reportSortName:
+		c.reportSort = "name"
+	case c.reportSortStorage:
+		c.reportSort = "storage"
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		si, sj := stats[i], stats[j]
+
+		switch c.reportSort {
+		case "consumers":
+			if si.Consumers != sj.Consumers {
+				return si.Consumers < sj.Consumers
+			}
+		case "msgs", "messages":
+			if si.Msgs != sj.Msgs {
+				return si.Msgs < sj.Msgs
+			}
+		case "storage":
+			if si.Storage != sj.Storage {
+				return si.Storage < sj.Storage
+			}
+		case "name":
+		default:
+			if si.Bytes != sj.Bytes {
+				return si.Bytes < sj.Bytes
+			}
+		}
+
+		return si.Name < sj.Name
+	})
 
 	table := tablewriter.CreateTable()
 	table.AddHeaders("Stream", "Consumers", "Messages", "Bytes", "Storage", "Template")
@@ -604,12 +892,7 @@ func (c *streamCmd) copyAndEditStream(cfg api.StreamConfig) (api.StreamConfig, e
 
 	if c.inputFile != "" {
 		var cfg api.StreamConfig
-		f, err := ioutil.ReadFile(c.inputFile)
-		if err != nil {
-			return api.StreamConfig{}, err
-		}
-
-		err = json.Unmarshal(f, &cfg)
+		err = loadConfigFile(c.inputFile, cfg.SchemaType(), &cfg)
 		if err != nil {
 			return api.StreamConfig{}, err
 		}
@@ -658,6 +941,10 @@ func (c *streamCmd) copyAndEditStream(cfg api.StreamConfig) (api.StreamConfig, e
 		cfg.MaxMsgSize = c.maxMsgSize
 	}
 
+	if c.replicas > 0 {
+		cfg.Replicas = c.replicas
+	}
+
 	if c.dupeWindow != "" {
 		dw, err := parseDurationString(c.dupeWindow)
 		if err != nil {
@@ -666,9 +953,29 @@ func (c *streamCmd) copyAndEditStream(cfg api.StreamConfig) (api.StreamConfig, e
 		cfg.Duplicates = dw
 	}
 
+	if c.dupeWindow != "" || c.maxAgeLimit != "" {
+		err := validateDupeWindow(cfg.Duplicates, cfg.MaxAge)
+		if err != nil {
+			return api.StreamConfig{}, err
+		}
+	}
+
 	return cfg, nil
 }
 
+// validateDupeWindow ensures the duplicate window is positive and within the maximum age, a zero window uses the server default
+func validateDupeWindow(dupeWindow time.Duration, maxAge time.Duration) error {
+	if dupeWindow < 0 {
+		return fmt.Errorf("duplicate window must be positive")
+	}
+
+	if maxAge > 0 && dupeWindow > maxAge {
+		return fmt.Errorf("duplicate window %v can not be larger than the maximum age %v", dupeWindow, maxAge)
+	}
+
+	return nil
+}
+
 func (c *streamCmd) editAction(pc *kingpin.ParseContext) error {
 	c.connectAndAskStream()
 
@@ -693,7 +1000,20 @@ func (c *streamCmd) editAction(pc *kingpin.ParseContext) error {
 
 	fmt.Printf("Differences (-old +new):\n%s", diff)
 	if !c.force {
-		ok, err := askConfirmation(fmt.Sprintf("Really edit Stream %s", c.stream), false)
+		prompt := fmt.Sprintf("Really edit Stream %s", c.stream)
+
+		oldCfg := sourceStream.Configuration()
+		if cfg.MaxAge > 0 && (oldCfg.MaxAge == 0 || cfg.MaxAge < oldCfg.MaxAge) {
+			info, err := sourceStream.LatestInformation()
+			kingpin.FatalIfError(err, "could not request Stream %s state", c.stream)
+
+			msgs, bytes := c.estimateMaxAgeLoss(info.State, cfg.MaxAge, time.Now())
+			if msgs > 0 {
+				prompt = fmt.Sprintf("Approximately %s messages (%s) are older than %v and will be removed, really edit Stream %s", humanize.Comma(int64(msgs)), humanize.IBytes(bytes), cfg.MaxAge, c.stream)
+			}
+		}
+
+		ok, err := askConfirmation(prompt, false)
 		kingpin.FatalIfError(err, "could not obtain confirmation")
 
 		if !ok {
@@ -713,6 +1033,22 @@ func (c *streamCmd) editAction(pc *kingpin.ParseContext) error {
 	return nil
 }
 
+// estimateMaxAgeLoss guesses how much data would be expired by maxAge assuming messages arrived at a steady rate between the first and last message
+func (c *streamCmd) estimateMaxAgeLoss(state api.StreamState, maxAge time.Duration, now time.Time) (msgs uint64, bytes uint64) {
+	cutoff := now.Add(-maxAge)
+
+	switch {
+	case state.Msgs == 0 || !state.FirstTime.Before(cutoff):
+		return 0, 0
+	case !state.LastTime.After(cutoff):
+		return state.Msgs, state.Bytes
+	}
+
+	ratio := float64(cutoff.Sub(state.FirstTime)) / float64(state.LastTime.Sub(state.FirstTime))
+
+	return uint64(float64(state.Msgs) * ratio), uint64(float64(state.Bytes) * ratio)
+}
+
 func (c *streamCmd) cpAction(pc *kingpin.ParseContext) error {
 	if c.stream == c.destination {
 		kingpin.Fatalf("source and destination Stream names cannot be the same")
@@ -727,12 +1063,35 @@ func (c *streamCmd) cpAction(pc *kingpin.ParseContext) error {
 	kingpin.FatalIfError(err, "could not copy Stream %s", c.stream)
 
 	cfg.Name = c.destination
+	cfg.Template = ""
+
+	if c.copyData {
+		err = c.checkCopyDataSubjects(sourceStream.Subjects(), cfg.Subjects)
+		kingpin.FatalIfError(err, "can not copy Stream data")
+	}
 
 	new, err := c.mgr.NewStreamFromDefault(cfg.Name, cfg)
 	kingpin.FatalIfError(err, "could not create Stream")
 
+	copied := 0
+	if c.copyData {
+		copied, err = c.copyStreamData(sourceStream, cfg.Subjects[0])
+		if err != nil {
+			// do not leave a partially copied Stream behind
+			derr := new.Delete()
+			if derr != nil {
+				log.Printf("Could not remove Stream %s after a failed copy: %s", c.destination, derr)
+			}
+		}
+		kingpin.FatalIfError(err, "could not copy Stream data")
+	}
+
 	if !c.json {
-		fmt.Printf("Stream %s was created\n\n", c.stream)
+		fmt.Printf("Stream %s was created\n\n", c.destination)
+
+		if c.copyData {
+			fmt.Printf("Copied %s messages to %q\n\n", humanize.Comma(int64(copied)), cfg.Subjects[0])
+		}
 	}
 
 	c.showStream(new)
@@ -740,6 +1099,64 @@ func (c *streamCmd) cpAction(pc *kingpin.ParseContext) error {
 	return nil
 }
 
+// checkCopyDataSubjects ensures messages published to the destination will not also be stored in the source
+func (c *streamCmd) checkCopyDataSubjects(source []string, dest []string) error {
+	if len(dest) != 1 || strings.ContainsAny(dest[0], "*>") {
+		return fmt.Errorf("the destination must have a single subject without wildcards, set using --subjects")
+	}
+
+	for _, subj := range source {
+		if subjectsCollide(subj, dest[0]) {
+			return fmt.Errorf("destination subject %q overlaps with source subject %q", dest[0], subj)
+		}
+	}
+
+	return nil
+}
+
+// copyStreamData republishes all messages in source to subject and returns how many were copied, original subjects are not retained
+func (c *streamCmd) copyStreamData(source *jsm.Stream, subject string) (int, error) {
+	info, err := source.LatestInformation()
+	if err != nil {
+		return 0, err
+	}
+
+	if info.State.Msgs == 0 {
+		return 0, nil
+	}
+
+	copied := 0
+	for seq := info.State.FirstSeq; seq <= info.State.LastSeq; seq++ {
+		sm, err := source.ReadMessage(int(seq))
+		if err != nil {
+			// deleted messages leave gaps in the sequence
+			if isMsgNotFound(err) {
+				continue
+			}
+
+			return copied, err
+		}
+
+		msg := nats.NewMsg(subject)
+		msg.Data = sm.Data
+		if len(sm.Header) > 0 {
+			msg.Header, err = decodeHeadersMsg(sm.Header)
+			if err != nil {
+				return copied, err
+			}
+		}
+
+		_, err = c.nc.RequestMsg(msg, timeout)
+		if err != nil {
+			return copied, fmt.Errorf("publishing message %d failed: %s", seq, err)
+		}
+
+		copied++
+	}
+
+	return copied, nil
+}
+
 func (c *streamCmd) showStreamConfig(cfg api.StreamConfig) {
 	fmt.Println("Configuration:")
 	fmt.Println()
@@ -826,6 +1243,22 @@ func (c *streamCmd) infoAction(_ *kingpin.ParseContext) error {
 
 	stream, err := c.mgr.LoadStream(c.stream)
 	kingpin.FatalIfError(err, "could not request Stream info")
+
+	if c.outFile != "" {
+		cfg := stream.Configuration()
+		// the template owner is set by the server when a Template creates the Stream
+		cfg.Template = ""
+
+		j, err := json.MarshalIndent(cfg, "", "  ")
+		kingpin.FatalIfError(err, "could not encode Stream configuration")
+
+		err = ioutil.WriteFile(c.outFile, j, 0644)
+		kingpin.FatalIfError(err, "could not save Stream configuration")
+
+		fmt.Printf("Saved configuration for Stream %s to %s\n", c.stream, c.outFile)
+		return nil
+	}
+
 	err = c.showStream(stream)
 	kingpin.FatalIfError(err, "could not show stream")
 
@@ -891,10 +1324,7 @@ func (c *streamCmd) prepareConfig() (cfg api.StreamConfig) {
 	var err error
 
 	if c.inputFile != "" {
-		f, err := ioutil.ReadFile(c.inputFile)
-		kingpin.FatalIfError(err, "invalid input")
-
-		err = json.Unmarshal(f, &cfg)
+		err = loadConfigFile(c.inputFile, cfg.SchemaType(), &cfg)
 		kingpin.FatalIfError(err, "invalid input")
 
 		if c.stream != "" {
@@ -909,7 +1339,7 @@ func (c *streamCmd) prepareConfig() (cfg api.StreamConfig) {
 	}
 
 	if c.stream == "" {
-		err = survey.AskOne(&survey.Input{
+		err = askOne(&survey.Input{
 			Message: "Stream Name",
 		}, &c.stream, survey.WithValidator(survey.Required))
 		kingpin.FatalIfError(err, "invalid input")
@@ -917,7 +1347,7 @@ func (c *streamCmd) prepareConfig() (cfg api.StreamConfig) {
 
 	if len(c.subjects) == 0 {
 		subjects := ""
-		err = survey.AskOne(&survey.Input{
+		err = askOne(&survey.Input{
 			Message: "Subjects to consume",
 			Help:    "Streams consume messages from subjects, this is a space or comma separated list that can include wildcards. Settable using --subjects",
 		}, &subjects, survey.WithValidator(survey.Required))
@@ -929,7 +1359,7 @@ func (c *streamCmd) prepareConfig() (cfg api.StreamConfig) {
 	c.subjects = c.splitCLISubjects()
 
 	if c.storage == "" {
-		err = survey.AskOne(&survey.Select{
+		err = askOne(&survey.Select{
 			Message: "Storage backend",
 			Options: []string{"file", "memory"},
 			Help:    "Streams are stored on the server, this can be one of many backends and all are usable in clustering mode. Settable using --storage",
@@ -940,7 +1370,7 @@ func (c *streamCmd) prepareConfig() (cfg api.StreamConfig) {
 	storage := c.storeTypeFromString(c.storage)
 
 	if c.retentionPolicyS == "" {
-		err = survey.AskOne(&survey.Select{
+		err = askOne(&survey.Select{
 			Message: "Retention Policy",
 			Options: []string{"Limits", "Interest", "Work Queue"},
 			Help:    "Messages are retained either based on limits like size and age (Limits), as long as there are Consumers (Interest) or until any worker processed them (Work Queue)",
@@ -950,7 +1380,7 @@ func (c *streamCmd) prepareConfig() (cfg api.StreamConfig) {
 	}
 
 	if c.discardPolicy == "" {
-		err = survey.AskOne(&survey.Select{
+		err = askOne(&survey.Select{
 			Message: "Discard Policy",
 			Options: []string{"New", "Old"},
 			Help:    "Once the Stream reach it's limits of size or messages the New policy will prevent further messages from being added while Old will delete old messages.",
@@ -975,7 +1405,7 @@ func (c *streamCmd) prepareConfig() (cfg api.StreamConfig) {
 	}
 
 	if c.maxAgeLimit == "" {
-		err = survey.AskOne(&survey.Input{
+		err = askOne(&survey.Input{
 			Message: "Maximum message age limit",
 			Default: "-1",
 			Help:    "Defines the oldest messages that can be stored in the Stream, any messages older than this period will be removed, -1 for unlimited. Supports units (s)econds, (m)inutes, (h)ours, (y)ears, (M)onths, (d)ays. Settable using --max-age",
@@ -989,7 +1419,7 @@ func (c *streamCmd) prepareConfig() (cfg api.StreamConfig) {
 	}
 
 	if c.maxMsgSize == 0 {
-		err = survey.AskOne(&survey.Input{
+		err = askOne(&survey.Input{
 			Message: "Maximum individual message size",
 			Default: "-1",
 			Help:    "Defines the maximum size any single message may be to be accepted by the Stream. Settable using --max-msg-size",
@@ -999,10 +1429,10 @@ func (c *streamCmd) prepareConfig() (cfg api.StreamConfig) {
 
 	var dupeWindow time.Duration
 	if c.dupeWindow == "" {
-		err = survey.AskOne(&survey.Input{
+		err = askOne(&survey.Input{
 			Message: "Duplicate tracking time window",
 			Default: "",
-			Help:    "Duplicate messages are identified by the Msg-Id headers and tracked within a window of this size. Supports units (s)econds, (m)inutes, (h)ours, (y)ears, (M)onths, (d)ays. Settable using --dupe-window.",
+			Help:    "Duplicate messages are identified by the Nats-Msg-Id headers and tracked within a window of this size. Supports units (s)econds, (m)inutes, (h)ours, (y)ears, (M)onths, (d)ays. Settable using --dupe-window.",
 		}, &c.dupeWindow)
 		kingpin.FatalIfError(err, "invalid input")
 	}
@@ -1010,6 +1440,9 @@ func (c *streamCmd) prepareConfig() (cfg api.StreamConfig) {
 	if c.dupeWindow != "" {
 		dupeWindow, err = parseDurationString(c.dupeWindow)
 		kingpin.FatalIfError(err, "invalid duplicate window format")
+
+		err = validateDupeWindow(dupeWindow, maxAge)
+		kingpin.FatalIfError(err, "invalid duplicate window")
 	}
 
 	cfg = api.StreamConfig{
@@ -1028,6 +1461,10 @@ func (c *streamCmd) prepareConfig() (cfg api.StreamConfig) {
 		Replicas:     1,
 	}
 
+	if c.replicas > 0 {
+		cfg.Replicas = c.replicas
+	}
+
 	return cfg
 }
 
@@ -1082,6 +1519,10 @@ func (c *streamCmd) addAction(pc *kingpin.ParseContext) (err error) {
 
 	c.showStream(str)
 
+	if str.DuplicateWindow() > 0 {
+		fmt.Printf("Messages published with the same Nats-Msg-Id header within %v are stored only once\n\n", str.DuplicateWindow())
+	}
+
 	return nil
 }
 
@@ -1129,13 +1570,89 @@ func (c *streamCmd) purgeAction(pc *kingpin.ParseContext) (err error) {
 	return nil
 }
 
-func (c *streamCmd) lsAction(_ *kingpin.ParseContext) error {
+func (c *streamCmd) findAction(_ *kingpin.ParseContext) error {
+	var idle time.Duration
+	var err error
+
+	if c.findIdle != "" {
+		idle, err = parseDurationString(c.findIdle)
+		kingpin.FatalIfError(err, "invalid idle duration")
+	}
+
 	_, mgr, err := prepareHelper("", natsOpts()...)
 	kingpin.FatalIfError(err, "setup failed")
 
-	streams, err := mgr.StreamNames(&jsm.StreamNamesFilter{Subject: c.filterSubject})
+	matches := func(info *api.StreamInfo) bool {
+		if c.findEmpty && info.State.Msgs > 0 {
+			return false
+		}
+
+		if idle > 0 && time.Since(info.State.LastTime) < idle {
+			return false
+		}
+
+		if c.replicas > 0 && info.Config.Replicas != c.replicas {
+			return false
+		}
+
+		if c.storage != "" && info.Config.Storage != c.storeTypeFromString(c.storage) {
+			return false
+		}
+
+		if c.filterSubject != "" {
+			for _, subj := range info.Config.Subjects {
+				if subjectsCollide(c.filterSubject, subj) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		return true
+	}
+
+	var found []string
+	err = mgr.EachStream(func(s *jsm.Stream) {
+		info, err := s.LatestInformation()
+		kingpin.FatalIfError(err, "could not get stream info for %s", s.Name())
+
+		if matches(info) {
+			found = append(found, s.Name())
+		}
+	})
 	kingpin.FatalIfError(err, "could not list Streams")
 
+	sort.Strings(found)
+	for _, name := range found {
+		fmt.Println(name)
+	}
+
+	return nil
+}
+
+func (c *streamCmd) lsAction(_ *kingpin.ParseContext) error {
+	_, mgr, err := prepareHelper("", natsOpts()...)
+	kingpin.FatalIfError(err, "setup failed")
+
+	var streams []string
+	if c.filterSubject == "" {
+		streams, err = mgr.StreamNames(nil)
+		kingpin.FatalIfError(err, "could not list Streams")
+	} else {
+		// filtered locally so that wildcard overlaps are found regardless of server support
+		err = mgr.EachStream(func(s *jsm.Stream) {
+			for _, subj := range s.Subjects() {
+				if subjectsCollide(c.filterSubject, subj) {
+					streams = append(streams, s.Name())
+					break
+				}
+			}
+		})
+		kingpin.FatalIfError(err, "could not list Streams")
+		sort.Strings(streams)
+	}
+
 	if c.json {
 		err = printJSON(streams)
 		kingpin.FatalIfError(err, "could not display Streams")
@@ -1162,12 +1679,147 @@ func (c *streamCmd) lsAction(_ *kingpin.ParseContext) error {
 	return nil
 }
 
+// isMsgNotFound determines if err indicates a message was deleted or does not exist, memory
+// stores report "no message found" and file stores "deleted message" for deleted messages
+func isMsgNotFound(err error) bool {
+	apiErr, ok := err.(api.ApiError)
+	return ok && (apiErr.NotFoundError() || apiErr.Description == "no message found" || apiErr.Description == "deleted message")
+}
+
+// seqRange is an inclusive range of Stream sequences
+type seqRange struct {
+	First uint64 `json:"first"`
+	Last  uint64 `json:"last"`
+}
+
+func (r seqRange) String() string {
+	if r.First == r.Last {
+		return strconv.FormatUint(r.First, 10)
+	}
+
+	return fmt.Sprintf("%d-%d", r.First, r.Last)
+}
+
+func formatSeqRanges(ranges []seqRange) string {
+	if len(ranges) == 0 {
+		return "none"
+	}
+
+	parts := make([]string, len(ranges))
+	for i, r := range ranges {
+		parts[i] = r.String()
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+func (c *streamCmd) gapsAction(_ *kingpin.ParseContext) error {
+	c.connectAndAskStream()
+
+	stream, err := c.mgr.LoadStream(c.stream)
+	kingpin.FatalIfError(err, "could not load Stream %s", c.stream)
+
+	state, err := stream.State()
+	kingpin.FatalIfError(err, "could not load Stream %s state", c.stream)
+
+	report := struct {
+		Present   []seqRange `json:"present"`
+		Absent    []seqRange `json:"absent"`
+		Unscanned []seqRange `json:"unscanned"`
+		Deleted   uint64     `json:"deleted"`
+		Scanned   bool       `json:"scanned"`
+	}{Present: []seqRange{}, Absent: []seqRange{}, Unscanned: []seqRange{}}
+
+	var first uint64 = 1
+	if state.Msgs > 0 {
+		first = state.FirstSeq
+	}
+
+	// sequences before the first message were purged or expired
+	if first > 1 {
+		report.Absent = append(report.Absent, seqRange{1, first - 1})
+	}
+
+	if state.Msgs > 0 {
+		report.Deleted = state.LastSeq - state.FirstSeq + 1 - state.Msgs
+	}
+
+	switch {
+	case state.Msgs == 0:
+		if state.LastSeq > 0 {
+			report.Absent = []seqRange{{1, state.LastSeq}}
+		}
+
+	case report.Deleted == 0:
+		report.Present = append(report.Present, seqRange{state.FirstSeq, state.LastSeq})
+
+	case !c.gapsScan:
+		// the range holds deleted messages but where they are is only known after a scan
+		report.Unscanned = append(report.Unscanned, seqRange{state.FirstSeq, state.LastSeq})
+
+	default:
+		report.Scanned = true
+
+		var current *seqRange
+		var present bool
+
+		for seq := state.FirstSeq; seq <= state.LastSeq; seq++ {
+			_, err := stream.ReadMessage(int(seq))
+			if err != nil && !isMsgNotFound(err) {
+				kingpin.FatalIfError(err, "could not read message %d", seq)
+			}
+
+			found := err == nil
+			if current != nil && found == present {
+				current.Last = seq
+				continue
+			}
+
+			if current != nil {
+				if present {
+					report.Present = append(report.Present, *current)
+				} else {
+					report.Absent = append(report.Absent, *current)
+				}
+			}
+
+			current = &seqRange{seq, seq}
+			present = found
+		}
+
+		if present {
+			report.Present = append(report.Present, *current)
+		} else {
+			report.Absent = append(report.Absent, *current)
+		}
+	}
+
+	if c.json {
+		printJSON(report)
+		return nil
+	}
+
+	fmt.Printf("Sequence gaps in Stream %s:\n\n", c.stream)
+	fmt.Printf("  Present: %s\n", formatSeqRanges(report.Present))
+	fmt.Printf("   Absent: %s\n", formatSeqRanges(report.Absent))
+	if len(report.Unscanned) > 0 {
+		fmt.Printf("Unscanned: %s\n", formatSeqRanges(report.Unscanned))
+	}
+
+	if report.Deleted > 0 && !report.Scanned {
+		fmt.Println()
+		fmt.Printf("%s messages were deleted between sequence %d and %d, use --scan to locate them\n", humanize.Comma(int64(report.Deleted)), state.FirstSeq, state.LastSeq)
+	}
+
+	return nil
+}
+
 func (c *streamCmd) rmMsgAction(_ *kingpin.ParseContext) (err error) {
 	c.connectAndAskStream()
 
 	if c.msgID == -1 {
 		id := ""
-		err = survey.AskOne(&survey.Input{
+		err = askOne(&survey.Input{
 			Message: "Message ID to remove",
 		}, &id, survey.WithValidator(survey.Required))
 		kingpin.FatalIfError(err, "invalid input")
@@ -1196,9 +1848,27 @@ func (c *streamCmd) rmMsgAction(_ *kingpin.ParseContext) (err error) {
 func (c *streamCmd) getAction(_ *kingpin.ParseContext) (err error) {
 	c.connectAndAskStream()
 
+	if c.msgTime != "" {
+		if c.msgID != -1 {
+			kingpin.Fatalf("cannot retrieve a message by both ID and time")
+		}
+
+		ts, err := time.Parse(time.RFC3339, c.msgTime)
+		kingpin.FatalIfError(err, "invalid time, expected RFC3339 format like 2006-01-02T15:04:05Z")
+
+		seq, err := c.sequenceForTime(ts)
+		kingpin.FatalIfError(err, "could not resolve a message in %s for %s", c.stream, c.msgTime)
+
+		if !c.json {
+			fmt.Printf("Resolved %s to sequence %d\n\n", ts.Format(time.RFC3339), seq)
+		}
+
+		c.msgID = int64(seq)
+	}
+
 	if c.msgID == -1 {
 		id := ""
-		err = survey.AskOne(&survey.Input{
+		err = askOne(&survey.Input{
 			Message: "Message ID to retrieve",
 		}, &id, survey.WithValidator(survey.Required))
 		kingpin.FatalIfError(err, "invalid input")
@@ -1240,6 +1910,56 @@ func (c *streamCmd) getAction(_ *kingpin.ParseContext) (err error) {
 	return nil
 }
 
+// sequenceForTime finds the stream sequence of the first message stored at or after ts using a short lived ephemeral consumer
+func (c *streamCmd) sequenceForTime(ts time.Time) (uint64, error) {
+	stream, err := c.mgr.LoadStream(c.stream)
+	if err != nil {
+		return 0, err
+	}
+
+	state, err := stream.State()
+	if err != nil {
+		return 0, err
+	}
+
+	if state.Msgs == 0 {
+		return 0, fmt.Errorf("stream is empty")
+	}
+
+	if ts.Before(state.FirstTime.Truncate(time.Second)) {
+		return 0, fmt.Errorf("time is before the first message stored at %s", state.FirstTime.Format(time.RFC3339))
+	}
+
+	if ts.After(state.LastTime) {
+		return 0, fmt.Errorf("time is after the last message stored at %s", state.LastTime.Format(time.RFC3339))
+	}
+
+	ib := nats.NewInbox()
+	sub, err := c.nc.SubscribeSync(ib)
+	if err != nil {
+		return 0, err
+	}
+	defer sub.Unsubscribe()
+
+	cons, err := c.mgr.NewConsumer(c.stream, jsm.DeliverySubject(ib), jsm.StartAtTime(ts), jsm.AcknowledgeNone())
+	if err != nil {
+		return 0, err
+	}
+	defer cons.Delete()
+
+	msg, err := sub.NextMsg(timeout)
+	if err != nil {
+		return 0, fmt.Errorf("no message received: %s", err)
+	}
+
+	info, err := jsm.ParseJSMsgMetadata(msg)
+	if err != nil {
+		return 0, err
+	}
+
+	return info.StreamSequence(), nil
+}
+
 func (c *streamCmd) connectAndAskStream() {
 	var err error
 
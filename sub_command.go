@@ -14,14 +14,25 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/nats-io/jsm.go"
 	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/reflect/protoreflect"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
@@ -30,6 +41,26 @@ type subCmd struct {
 	queue   string
 	raw     bool
 	jsAck   bool
+	hdrFilt []string
+	count   int
+	wait    time.Duration
+
+	binary  bool
+	outFile string
+	decomp  string
+	dumpDir string
+	jsonl   bool
+	drain   bool
+	hdrOnly bool
+	dur     time.Duration
+
+	protoFile string
+	protoMsg  string
+
+	ordered  bool
+	startSeq uint64
+	all      bool
+	last     bool
 }
 
 func configureSubCommand(app *kingpin.Application) {
@@ -39,29 +70,115 @@ func configureSubCommand(app *kingpin.Application) {
 	act.Flag("queue", "Subscribe to a named queue group").StringVar(&c.queue)
 	act.Flag("raw", "Show the raw data received").Short('r').BoolVar(&c.raw)
 	act.Flag("ack", "Acknowledge JetStream message that have the correct metadata").BoolVar(&c.jsAck)
+	act.Flag("filter-header", "Only show messages with the header Key=Value, Key= matches any value").PlaceHolder("KEY=VALUE").StringsVar(&c.hdrFilt)
+	act.Flag("count", "Quit after receiving this many messages").IntVar(&c.count)
+	act.Flag("wait", "When using --count fail if the messages are not received within this duration").DurationVar(&c.wait)
+	act.Flag("binary", "Writes message bodies to STDOUT exactly as received").BoolVar(&c.binary)
+	act.Flag("out", "Writes message bodies to a file exactly as received").PlaceHolder("FILE").StringVar(&c.outFile)
+	act.Flag("decompress", "Decompresses message bodies using gzip before showing them").PlaceHolder("gzip").EnumVar(&c.decomp, "gzip")
+	act.Flag("drain", "On interrupt drain the connection so in-flight messages are handled and acknowledged before exiting").BoolVar(&c.drain)
+	act.Flag("duration", "Stops after this long and shows how many messages were received").PlaceHolder("DURATION").DurationVar(&c.dur)
+	act.Flag("headers-only", "Shows only the subject, size and headers of messages").BoolVar(&c.hdrOnly)
+	act.Flag("jsonl", "Shows each message as a single line JSON object").BoolVar(&c.jsonl)
+	act.Flag("proto", "Compiled protobuf FileDescriptorSet used to decode message bodies, see --message").PlaceHolder("DESCRIPTOR").ExistingFileVar(&c.protoFile)
+	act.Flag("message", "Protobuf message type to decode message bodies from").PlaceHolder("TYPE").StringVar(&c.protoMsg)
+	act.Flag("dump-dir", "Writes each message body to a numbered file in this directory with headers in FILE.headers").PlaceHolder("DIR").StringVar(&c.dumpDir)
+	act.Flag("ordered", "Reads the JetStream Stream holding the subject in order using an ephemeral Consumer that recovers from gaps").BoolVar(&c.ordered)
+	act.Flag("start-seq", "When using --ordered, start at this Stream sequence").Uint64Var(&c.startSeq)
+	act.Flag("all", "When using --ordered, start at the first message in the Stream").BoolVar(&c.all)
+	act.Flag("last", "When using --ordered, start at the last message in the Stream").BoolVar(&c.last)
 }
 
 func (c *subCmd) subscribe(_ *kingpin.ParseContext) error {
-	nc, err := newNatsConn("", natsOpts()...)
+	for _, filter := range c.hdrFilt {
+		if !strings.Contains(filter, "=") || strings.HasPrefix(filter, "=") {
+			return fmt.Errorf("header filter %q is invalid, expected Key=Value or Key=", filter)
+		}
+	}
+
+	var nc *nats.Conn
+	var mgr *jsm.Manager
+	var err error
+
+	if c.ordered {
+		if c.queue != "" {
+			return fmt.Errorf("--ordered cannot be used with --queue")
+		}
+
+		nc, mgr, err = prepareHelper("", natsOpts()...)
+	} else {
+		if c.startSeq > 0 || c.all || c.last {
+			return fmt.Errorf("--start-seq, --all and --last requires --ordered")
+		}
+
+		nc, err = newNatsConn("", natsOpts()...)
+	}
 	if err != nil {
 		return err
 	}
 	defer nc.Close()
 
+	if c.dumpDir != "" {
+		err = os.MkdirAll(c.dumpDir, 0700)
+		if err != nil {
+			return err
+		}
+	}
+
+	var msgType protoreflect.MessageDescriptor
+	if c.protoFile != "" || c.protoMsg != "" {
+		if c.protoFile == "" || c.protoMsg == "" {
+			return fmt.Errorf("decoding protobuf messages requires both --proto and --message")
+		}
+
+		msgType, err = protoMessageType(c.protoFile, c.protoMsg)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.hdrOnly && (c.raw || c.binary || c.outFile != "" || c.jsonl) {
+		return fmt.Errorf("--headers-only can not be used with --raw, --binary, --out or --jsonl")
+	}
+
+	var out io.WriteCloser
+	if c.binary || c.outFile != "" {
+		out, err = rawOutput(c.binary, c.outFile)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		c.raw = true
+	}
+
 	i := 0
 	mu := sync.Mutex{}
+	done := make(chan struct{})
 
 	handler := func(m *nats.Msg) {
 		mu.Lock()
 		defer mu.Unlock()
 
-		i += 1
-
 		var info *jsm.MsgInfo
 		if m.Reply != "" {
 			info, _ = jsm.ParseJSMsgMetadata(m)
 		}
 
+		// messages hidden by the header filter or received after --count are left unacknowledged for redelivery
+		if !msgMatchesHeaders(m, c.hdrFilt) {
+			return
+		}
+
+		if c.count > 0 && i >= c.count {
+			return
+		}
+
+		i += 1
+		if c.count > 0 && i == c.count {
+			defer close(done)
+		}
+
 		if c.jsAck && info != nil {
 			defer func() {
 				err = m.Ack()
@@ -72,15 +189,45 @@ func (c *subCmd) subscribe(_ *kingpin.ParseContext) error {
 			}()
 		}
 
+		m.Data = protoDecode(msgType, decompressBody(c.decomp, m.Data))
+
+		if c.dumpDir != "" {
+			err := dumpMsg(c.dumpDir, i, m)
+			if err != nil {
+				log.Printf("Could not save message %d: %s", i, err)
+			}
+		}
+
+		if out != nil {
+			_, err := out.Write(m.Data)
+			if err != nil {
+				log.Printf("Could not write message: %s", err)
+			}
+			return
+		}
+
+		if c.jsonl {
+			err := printJSONLMsg(m, info)
+			if err != nil {
+				log.Printf("Could not encode message: %s", err)
+			}
+			return
+		}
+
 		if c.raw {
 			fmt.Println(string(m.Data))
 			return
 		}
 
+		size := ""
+		if c.hdrOnly {
+			size = fmt.Sprintf(" with %s body", humanize.IBytes(uint64(len(m.Data))))
+		}
+
 		if info == nil {
-			fmt.Printf("[#%d] Received on %q\n", i, m.Subject)
+			fmt.Printf("[#%d] Received on %q%s\n", i, m.Subject, size)
 		} else {
-			fmt.Printf("[#%d] Received JetStream message: consumer: %s > %s / subject: %s / delivered: %d / consumer seq: %d / stream seq: %d / ack: %v\n", i, info.Stream(), info.Consumer(), m.Subject, info.Delivered(), info.ConsumerSequence(), info.StreamSequence(), c.jsAck)
+			fmt.Printf("[#%d] Received JetStream message: consumer: %s > %s / subject: %s / delivered: %d / consumer seq: %d / stream seq: %d / ack: %v%s\n", i, info.Stream(), info.Consumer(), m.Subject, info.Delivered(), info.ConsumerSequence(), info.StreamSequence(), c.jsAck, size)
 		}
 
 		if len(m.Header) > 0 {
@@ -93,13 +240,20 @@ func (c *subCmd) subscribe(_ *kingpin.ParseContext) error {
 			fmt.Println()
 		}
 
+		if c.hdrOnly {
+			if len(m.Header) == 0 {
+				fmt.Println()
+			}
+			return
+		}
+
 		fmt.Println(string(m.Data))
 		if !strings.HasSuffix(string(m.Data), "\n") {
 			fmt.Println()
 		}
 	}
 
-	if !c.raw {
+	if !c.raw && !c.jsonl {
 		if c.jsAck {
 			log.Printf("Subscribing on %s with acknowledgement of JetStream messages\n", c.subject)
 		} else {
@@ -107,10 +261,17 @@ func (c *subCmd) subscribe(_ *kingpin.ParseContext) error {
 		}
 	}
 
-	if c.queue != "" {
-		nc.QueueSubscribe(c.subject, c.queue, handler)
-	} else {
-		nc.Subscribe(c.subject, handler)
+	var sub interface{ Unsubscribe() error }
+	switch {
+	case c.ordered:
+		sub, err = c.subscribeOrdered(nc, mgr, handler)
+	case c.queue != "":
+		sub, err = nc.QueueSubscribe(c.subject, c.queue, handler)
+	default:
+		sub, err = nc.Subscribe(c.subject, handler)
+	}
+	if err != nil {
+		return err
 	}
 	nc.Flush()
 
@@ -119,7 +280,289 @@ func (c *subCmd) subscribe(_ *kingpin.ParseContext) error {
 		return err
 	}
 
-	<-context.Background().Done()
+	var ic chan os.Signal
+	if c.drain {
+		ic = make(chan os.Signal, 1)
+		signal.Notify(ic, os.Interrupt)
+	}
+
+	var expired <-chan time.Time
+	start := time.Now()
+	if c.dur > 0 {
+		expired = time.After(c.dur)
+	}
+
+	if c.count == 0 {
+		select {
+		case <-ic:
+			return c.drainConn(nc, &mu, &i)
+		case <-expired:
+			return c.finishDuration(sub, &mu, &i, start)
+		case <-context.Background().Done():
+			return nil
+		}
+	}
+
+	ctx := context.Background()
+	if c.wait > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.wait)
+		defer cancel()
+	}
+
+	select {
+	case <-done:
+		return sub.Unsubscribe()
+	case <-ic:
+		return c.drainConn(nc, &mu, &i)
+	case <-expired:
+		return c.finishDuration(sub, &mu, &i, start)
+	case <-ctx.Done():
+		sub.Unsubscribe()
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		return fmt.Errorf("timeout waiting for %d messages, received %d", c.count, i)
+	}
+}
+
+// finishDuration stops the subscription once --duration has passed and reports the effective receive rate
+func (c *subCmd) finishDuration(sub interface{ Unsubscribe() error }, mu *sync.Mutex, cnt *int, start time.Time) error {
+	err := sub.Unsubscribe()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	elapsed := time.Since(start)
+	log.Printf("Received %s messages in %v, %s msgs/sec", humanize.Comma(int64(*cnt)), elapsed.Round(time.Millisecond), humanize.Comma(int64(float64(*cnt)/elapsed.Seconds())))
+
+	return err
+}
+
+// drainConn drains nc and waits for it to close, reporting how many messages were handled while draining
+func (c *subCmd) drainConn(nc *nats.Conn, mu *sync.Mutex, cnt *int) error {
+	mu.Lock()
+	before := *cnt
+	mu.Unlock()
+
+	closed := make(chan struct{})
+	nc.SetClosedHandler(func(_ *nats.Conn) { close(closed) })
+
+	log.Printf("Draining...")
+	err := nc.Drain()
+	if err != nil {
+		return err
+	}
+	<-closed
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	log.Printf("Drained %d messages, received %d in total", *cnt-before, *cnt)
+
+	return nil
+}
+
+func (c *subCmd) subscribeOrdered(nc *nats.Conn, mgr *jsm.Manager, handler nats.MsgHandler) (*orderedConsumer, error) {
+	var start jsm.ConsumerOption
+	switch {
+	case c.startSeq > 0 && !c.all && !c.last:
+		start = jsm.StartAtSequence(c.startSeq)
+	case c.all && c.startSeq == 0 && !c.last:
+		start = jsm.DeliverAllAvailable()
+	case c.last && c.startSeq == 0 && !c.all:
+		start = jsm.StartWithLastReceived()
+	case c.startSeq == 0 && !c.all && !c.last:
+		start = jsm.StartWithNextReceived()
+	default:
+		return nil, fmt.Errorf("only one of --start-seq, --all and --last can be given")
+	}
+
+	stream, err := streamForSubject(mgr, c.subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.raw {
+		log.Printf("Reading Stream %s in order", stream.Name())
+	}
+
+	filter := c.subject
+	if len(stream.Subjects()) == 1 && stream.Subjects()[0] == c.subject {
+		filter = ""
+	}
+
+	o := &orderedConsumer{nc: nc, mgr: mgr, stream: stream.Name(), filter: filter, handler: handler}
+	err = o.start(start)
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// streamForSubject finds the only Stream that stores messages matching subject
+func streamForSubject(mgr *jsm.Manager, subject string) (*jsm.Stream, error) {
+	var found []*jsm.Stream
+
+	err := mgr.EachStream(func(s *jsm.Stream) {
+		for _, ss := range s.Subjects() {
+			if subjectsCollide(ss, subject) {
+				found = append(found, s)
+				return
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("no Stream stores messages for subject %s", subject)
+	case 1:
+		return found[0], nil
+	default:
+		return nil, fmt.Errorf("%d Streams store messages for subject %s", len(found), subject)
+	}
+}
+
+// orderedConsumer delivers messages from an ephemeral push Consumer in Stream order, when a gap
+// in the Consumer sequence is seen the Consumer is recreated from the last delivered Stream sequence
+type orderedConsumer struct {
+	nc      *nats.Conn
+	mgr     *jsm.Manager
+	stream  string
+	filter  string
+	handler nats.MsgHandler
+
+	consumer *jsm.Consumer
+	sub      *nats.Subscription
+	cseq     uint64
+	sseq     uint64
+	mu       sync.Mutex
+}
+
+func (o *orderedConsumer) start(start jsm.ConsumerOption) (err error) {
+	ib := nats.NewInbox()
+	o.cseq = 0
+
+	o.sub, err = o.nc.Subscribe(ib, o.deliver)
+	if err != nil {
+		return err
+	}
+
+	opts := []jsm.ConsumerOption{jsm.DeliverySubject(ib), jsm.AcknowledgeNone(), start}
+	if o.filter != "" {
+		opts = append(opts, jsm.FilterStreamBySubject(o.filter))
+	}
+
+	o.consumer, err = o.mgr.NewConsumer(o.stream, opts...)
+	if err != nil {
+		o.sub.Unsubscribe()
+		return err
+	}
 
 	return nil
 }
+
+func (o *orderedConsumer) deliver(m *nats.Msg) {
+	o.mu.Lock()
+
+	if m.Sub != o.sub {
+		o.mu.Unlock()
+		return
+	}
+
+	info, err := jsm.ParseJSMsgMetadata(m)
+	if err != nil {
+		o.mu.Unlock()
+		log.Printf("Could not parse JetStream metadata: %s", err)
+		return
+	}
+
+	if info.ConsumerSequence() != o.cseq+1 {
+		log.Printf("Detected a gap after Stream sequence %d, recreating the Consumer", o.sseq)
+
+		o.sub.Unsubscribe()
+		o.consumer.Delete()
+		err = o.start(jsm.StartAtSequence(o.sseq + 1))
+		o.mu.Unlock()
+		if err != nil {
+			log.Printf("Could not recreate the Consumer: %s", err)
+		}
+
+		return
+	}
+
+	o.cseq = info.ConsumerSequence()
+	o.sseq = info.StreamSequence()
+	o.mu.Unlock()
+
+	o.handler(m)
+}
+
+// Unsubscribe stops receiving messages and removes the Consumer
+func (o *orderedConsumer) Unsubscribe() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.sub.Unsubscribe()
+
+	return o.consumer.Delete()
+}
+
+type jsonlMsg struct {
+	Subject string      `json:"subject"`
+	Reply   string      `json:"reply,omitempty"`
+	Time    time.Time   `json:"time"`
+	Headers http.Header `json:"headers,omitempty"`
+	Data    []byte      `json:"data"`
+}
+
+// printJSONLMsg writes m to STDOUT as a single line of JSON, JetStream messages use their stored time
+func printJSONLMsg(m *nats.Msg, info *jsm.MsgInfo) error {
+	msg := jsonlMsg{
+		Subject: m.Subject,
+		Reply:   m.Reply,
+		Time:    time.Now().UTC(),
+		Headers: m.Header,
+		Data:    m.Data,
+	}
+
+	if info != nil {
+		msg.Time = info.TimeStamp().UTC()
+	}
+
+	j, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(os.Stdout, string(j))
+	return err
+}
+
+// dumpMsg saves the body of message number i into dir with its headers in a sidecar file that pub --from-dir can replay
+func dumpMsg(dir string, i int, m *nats.Msg) error {
+	path := filepath.Join(dir, fmt.Sprintf("%08d", i))
+
+	err := ioutil.WriteFile(path, m.Data, 0600)
+	if err != nil {
+		return err
+	}
+
+	if len(m.Header) == 0 {
+		return nil
+	}
+
+	var hdrs bytes.Buffer
+	for h, vals := range m.Header {
+		for _, val := range vals {
+			fmt.Fprintf(&hdrs, "%s: %s\n", h, val)
+		}
+	}
+
+	return ioutil.WriteFile(path+".headers", hdrs.Bytes(), 0600)
+}
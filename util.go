@@ -16,12 +16,20 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"net/textproto"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -29,7 +37,16 @@ import (
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/dustin/go-humanize"
+	"github.com/ghodss/yaml"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+	"golang.org/x/crypto/ssh/terminal"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
 	"gopkg.in/alecthomas/kingpin.v2"
 
 	"github.com/nats-io/jsm.go"
@@ -64,7 +81,7 @@ func selectConsumer(mgr *jsm.Manager, stream string, consumer string, force bool
 	default:
 		c := ""
 
-		err = survey.AskOne(&survey.Select{
+		err = askOne(&survey.Select{
 			Message: "Select a Consumer",
 			Options: consumers,
 		}, &c)
@@ -103,7 +120,7 @@ func selectStreamTemplate(mgr *jsm.Manager, template string, force bool) (string
 	default:
 		s := ""
 
-		err = survey.AskOne(&survey.Select{
+		err = askOne(&survey.Select{
 			Message: "Select a Stream Template",
 			Options: templates,
 		}, &s)
@@ -143,7 +160,7 @@ func selectStream(mgr *jsm.Manager, stream string, force bool) (string, error) {
 	default:
 		s := ""
 
-		err = survey.AskOne(&survey.Select{
+		err = askOne(&survey.Select{
 			Message: "Select a Stream",
 			Options: streams,
 		}, &s)
@@ -219,10 +236,44 @@ func parseDurationString(dstr string) (dur time.Duration, err error) {
 	return dur, nil
 }
 
+// promptsEnabled is false when prompts are disabled using --no-prompt or STDIN is not a terminal
+func promptsEnabled() bool {
+	return !noPrompt && terminal.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// askOne is survey.AskOne that fails instead of prompting when prompts are disabled
+func askOne(p survey.Prompt, response interface{}, opts ...survey.AskOpt) error {
+	if !promptsEnabled() {
+		msg := ""
+		switch q := p.(type) {
+		case *survey.Input:
+			msg = q.Message
+		case *survey.Select:
+			msg = q.Message
+		case *survey.Confirm:
+			msg = q.Message
+		}
+
+		return fmt.Errorf("can not ask %q when prompts are disabled, supply the value using flags or arguments", msg)
+	}
+
+	return survey.AskOne(p, response, opts...)
+}
+
+// askConfirmation asks a yes or no question, when prompts are disabled a true default is used and a false
+// default, used by destructive actions, fails so that --force has to be given
 func askConfirmation(prompt string, dflt bool) (bool, error) {
+	if !promptsEnabled() {
+		if dflt {
+			return true, nil
+		}
+
+		return false, fmt.Errorf("prompts are disabled, use --force to confirm %q", prompt)
+	}
+
 	ans := dflt
 
-	err := survey.AskOne(&survey.Confirm{
+	err := askOne(&survey.Confirm{
 		Message: prompt,
 		Default: dflt,
 	}, &ans)
@@ -232,7 +283,7 @@ func askConfirmation(prompt string, dflt bool) (bool, error) {
 
 func askOneBytes(prompt string, dflt string, help string) (int64, error) {
 	val := ""
-	err := survey.AskOne(&survey.Input{
+	err := askOne(&survey.Input{
 		Message: prompt,
 		Default: dflt,
 		Help:    help,
@@ -255,7 +306,7 @@ func askOneBytes(prompt string, dflt string, help string) (int64, error) {
 
 func askOneInt(prompt string, dflt string, help string) (int64, error) {
 	val := ""
-	err := survey.AskOne(&survey.Input{
+	err := askOne(&survey.Input{
 		Message: prompt,
 		Default: dflt,
 		Help:    help,
@@ -286,6 +337,320 @@ func splitString(s string) []string {
 	})
 }
 
+// subjectsCollide determines if two subjects, which may include wildcards, could both match a single literal subject
+func subjectsCollide(a string, b string) bool {
+	at := strings.Split(a, ".")
+	bt := strings.Split(b, ".")
+
+	for i := 0; i < len(at) && i < len(bt); i++ {
+		if at[i] == ">" || bt[i] == ">" {
+			return true
+		}
+
+		if at[i] == "*" || bt[i] == "*" || at[i] == bt[i] {
+			continue
+		}
+
+		return false
+	}
+
+	return len(at) == len(bt)
+}
+
+// protoMessageType finds the message type name in the FileDescriptorSet stored in file, as produced by
+// protoc --descriptor_set_out with --include_imports
+func protoMessageType(file string, name string) (protoreflect.MessageDescriptor, error) {
+	f, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	err = proto.Unmarshal(f, set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor set %s: %s", file, err)
+	}
+
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor set %s: %s", file, err)
+	}
+
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("could not find message %s in %s: %s", name, file, err)
+	}
+
+	md, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s in %s is not a message", name, file)
+	}
+
+	return md, nil
+}
+
+// protoEncode converts the JSON document data to the protobuf wire format of message type md
+func protoEncode(md protoreflect.MessageDescriptor, data []byte) ([]byte, error) {
+	msg := dynamicpb.NewMessage(md)
+	err := protojson.Unmarshal(data, msg)
+	if err != nil {
+		return nil, fmt.Errorf("could not convert JSON body to %s: %s", md.FullName(), err)
+	}
+
+	return proto.Marshal(msg)
+}
+
+// protoDecode converts the protobuf wire format data of message type md to JSON, when that fails a warning is logged and data is returned unchanged
+func protoDecode(md protoreflect.MessageDescriptor, data []byte) []byte {
+	if md == nil {
+		return data
+	}
+
+	msg := dynamicpb.NewMessage(md)
+	err := proto.Unmarshal(data, msg)
+	if err != nil {
+		log.Printf("Could not decode %s message, showing it as received: %s", md.FullName(), err)
+		return data
+	}
+
+	j, err := protojson.MarshalOptions{Multiline: true}.Marshal(msg)
+	if err != nil {
+		log.Printf("Could not decode %s message, showing it as received: %s", md.FullName(), err)
+		return data
+	}
+
+	return j
+}
+
+// loadConfigFile reads a JSON or YAML configuration from file into cfg, the file is validated against the JSON
+// Schema schemaType first so that errors refer to the fields as they appear in the file
+func loadConfigFile(file string, schemaType string, cfg interface{}) error {
+	f, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	j, err := yaml.YAMLToJSON(f)
+	if err != nil {
+		return fmt.Errorf("could not parse %s: %s", file, err)
+	}
+
+	var doc interface{}
+	err = json.Unmarshal(j, &doc)
+	if err != nil {
+		return fmt.Errorf("could not parse %s: %s", file, err)
+	}
+
+	ok, errs := new(SchemaValidator).ValidateStruct(doc, schemaType)
+	if !ok {
+		return fmt.Errorf("%s is not a valid configuration:\n\t%s", file, strings.Join(errs, "\n\t"))
+	}
+
+	return json.Unmarshal(j, cfg)
+}
+
+// rawOutput is where --binary and --out write message bodies byte-for-byte, STDOUT when binary is set otherwise file
+func rawOutput(binary bool, file string) (io.WriteCloser, error) {
+	switch {
+	case binary && file != "":
+		return nil, fmt.Errorf("--binary and --out cannot be used together")
+	case binary:
+		return os.Stdout, nil
+	default:
+		return os.Create(file)
+	}
+}
+
+// decompressBody decompresses data using algorithm, when that fails a warning is logged and data is returned unchanged
+func decompressBody(algorithm string, data []byte) []byte {
+	if algorithm == "" {
+		return data
+	}
+
+	var out []byte
+	var err error
+
+	switch algorithm {
+	case "gzip":
+		var r *gzip.Reader
+		r, err = gzip.NewReader(bytes.NewReader(data))
+		if err == nil {
+			out, err = ioutil.ReadAll(r)
+		}
+	default:
+		err = fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+
+	if err != nil {
+		log.Printf("Could not decompress message body, showing it as received: %s", err)
+		return data
+	}
+
+	return out
+}
+
+// prettyJSONBody indents data when it is valid JSON and returns it unchanged otherwise
+func prettyJSONBody(data []byte) []byte {
+	if !json.Valid(data) {
+		return data
+	}
+
+	var out bytes.Buffer
+	err := json.Indent(&out, bytes.TrimSpace(data), "", "  ")
+	if err != nil {
+		return data
+	}
+
+	return out.Bytes()
+}
+
+// translateBody passes data on STDIN to command run using the shell and returns its output
+func translateBody(command string, data []byte) ([]byte, error) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("translate command %q failed: %s", command, err)
+	}
+
+	return bytes.TrimSuffix(out, []byte("\n")), nil
+}
+
+// subjectRemap rewrites subjects matching from into to, wildcards in from are carried over to the matching wildcards in to
+type subjectRemap struct {
+	from []string
+	to   []string
+}
+
+// parseSubjectRemap parses OLD:NEW, both sides must have the same wildcards in the same order
+func parseSubjectRemap(remap string) (*subjectRemap, error) {
+	parts := strings.Split(remap, ":")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("subject remap %q is invalid, expected OLD:NEW", remap)
+	}
+
+	wildcards := func(tokens []string) string {
+		w := ""
+		for _, t := range tokens {
+			if t == "*" || t == ">" {
+				w += t
+			}
+		}
+		return w
+	}
+
+	r := &subjectRemap{from: strings.Split(parts[0], "."), to: strings.Split(parts[1], ".")}
+	if wildcards(r.from) != wildcards(r.to) {
+		return nil, fmt.Errorf("subject remap %q is invalid, OLD and NEW must have the same wildcards", remap)
+	}
+
+	return r, nil
+}
+
+// Apply rewrites subject when it matches the remap, false is returned when it does not match
+func (r *subjectRemap) Apply(subject string) (string, bool) {
+	st := strings.Split(subject, ".")
+	var captured []string
+
+	for i, t := range r.from {
+		switch {
+		case i >= len(st):
+			return "", false
+		case t == ">":
+			captured = append(captured, strings.Join(st[i:], "."))
+		case t == "*":
+			captured = append(captured, st[i])
+		case t != st[i]:
+			return "", false
+		}
+
+		if t == ">" {
+			break
+		}
+
+		if i == len(r.from)-1 && len(st) != len(r.from) {
+			return "", false
+		}
+	}
+
+	var result []string
+	for _, t := range r.to {
+		if t == "*" || t == ">" {
+			result = append(result, captured[0])
+			captured = captured[1:]
+			continue
+		}
+
+		result = append(result, t)
+	}
+
+	return strings.Join(result, "."), true
+}
+
+// traceDialer wraps connections to log the NATS protocol sent and received, TLS connections will show encrypted data
+type traceDialer struct {
+	net.Dialer
+}
+
+type traceConn struct {
+	net.Conn
+
+	rbuf []byte
+	wbuf []byte
+}
+
+var traceRedact = regexp.MustCompile(`"(pass|auth_token|sig|jwt)":"[^"]*"`)
+
+func (d *traceDialer) Dial(network, address string) (net.Conn, error) {
+	conn, err := d.Dialer.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &traceConn{Conn: conn}, nil
+}
+
+// trace logs all complete lines in b, partial lines are kept in buf till the rest arrives
+func (c *traceConn) trace(dir string, buf []byte, b []byte) []byte {
+	buf = append(buf, b...)
+
+	for {
+		idx := bytes.Index(buf, []byte("\r\n"))
+		if idx == -1 {
+			return buf
+		}
+
+		fmt.Fprintf(os.Stderr, "%s %s\n", dir, traceRedact.ReplaceAll(buf[:idx], []byte(`"$1":"[redacted]"`)))
+		buf = buf[idx+2:]
+	}
+}
+
+func (c *traceConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.rbuf = c.trace("<<<", c.rbuf, b[:n])
+	}
+
+	return n, err
+}
+
+func (c *traceConn) Write(b []byte) (int, error) {
+	c.wbuf = c.trace(">>>", c.wbuf, b)
+
+	return c.Conn.Write(b)
+}
+
+// traceOpts enables protocol tracing when --trace is given
+func traceOpts() []nats.Option {
+	if !trace {
+		return nil
+	}
+
+	return []nats.Option{nats.SetCustomDialer(&traceDialer{net.Dialer{Timeout: nats.DefaultTimeout}})}
+}
+
 func natsOpts() []nats.Option {
 	if config == nil {
 		return []nats.Option{}
@@ -294,6 +659,10 @@ func natsOpts() []nats.Option {
 	opts, err := config.NATSOptions()
 	kingpin.FatalIfError(err, "configuration error")
 
+	inline, err := inlineAuthOpts(userJWT, nkeySeed)
+	kingpin.FatalIfError(err, "configuration error")
+	opts = append(opts, inline...)
+
 	totalWait := 10 * time.Minute
 	reconnectDelay := time.Second
 
@@ -321,6 +690,38 @@ func natsOpts() []nats.Option {
 	}...)
 }
 
+// inlineAuthOpts authenticates using a JWT and NKEY seed given directly rather than in a credentials file, a seed
+// without a JWT authenticates using just the NKEY
+func inlineAuthOpts(jwt string, seed string) ([]nats.Option, error) {
+	if jwt == "" && seed == "" {
+		return nil, nil
+	}
+
+	if seed == "" {
+		return nil, fmt.Errorf("a NKEY seed is required when using a JWT")
+	}
+
+	kp, err := nkeys.FromSeed([]byte(seed))
+	if err != nil {
+		return nil, fmt.Errorf("invalid NKEY seed: %s", err)
+	}
+
+	sign := func(nonce []byte) ([]byte, error) {
+		return kp.Sign(nonce)
+	}
+
+	if jwt != "" {
+		return []nats.Option{nats.UserJWT(func() (string, error) { return jwt, nil }, sign)}, nil
+	}
+
+	pub, err := kp.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return []nats.Option{nats.Nkey(pub, sign)}, nil
+}
+
 func newNatsConn(servers string, opts ...nats.Option) (*nats.Conn, error) {
 	if config == nil {
 		if ctxError != nil {
@@ -337,9 +738,32 @@ func newNatsConn(servers string, opts ...nats.Option) (*nats.Conn, error) {
 		servers = config.ServerURL()
 	}
 
+	err := checkServerURLs(servers)
+	if err != nil {
+		return nil, err
+	}
+
 	return nats.Connect(servers, opts...)
 }
 
+// checkServerURLs rejects WebSocket URLs that the nats.go version in use can not connect to, without this
+// the client connects to the host and port and fails to find a NATS server there
+func checkServerURLs(servers string) error {
+	for _, s := range strings.Split(servers, ",") {
+		u, err := url.Parse(strings.TrimSpace(s))
+		if err != nil {
+			continue
+		}
+
+		switch strings.ToLower(u.Scheme) {
+		case "ws", "wss":
+			return fmt.Errorf("WebSocket URL %s://%s is not supported, connect to a NATS client port using a nats:// or tls:// URL", u.Scheme, u.Host)
+		}
+	}
+
+	return nil
+}
+
 func prepareHelper(servers string, opts ...nats.Option) (*nats.Conn, *jsm.Manager, error) {
 	if config == nil {
 		if ctxError != nil {
@@ -431,15 +855,67 @@ func decodeHeadersMsg(data []byte) (http.Header, error) {
 func parseStringsToHeader(hdrs []string, msg *nats.Msg) error {
 	for _, hdr := range hdrs {
 		parts := strings.SplitN(hdr, ":", 2)
-		if len(parts) != 2 {
-			return fmt.Errorf("invalid header %q", hdr)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return fmt.Errorf("header %q is invalid, expected Key:Value", hdr)
 		}
+
 		msg.Header.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
 	}
 
 	return nil
 }
 
+// msgMatchesHeaders checks that a message has all the headers in filters, each filter is Key=Value or Key= to match any value
+func msgMatchesHeaders(m *nats.Msg, filters []string) bool {
+	for _, filter := range filters {
+		parts := strings.SplitN(filter, "=", 2)
+		vals, ok := m.Header[http.CanonicalHeaderKey(strings.TrimSpace(parts[0]))]
+		if !ok {
+			return false
+		}
+
+		if len(parts) == 1 || parts[1] == "" {
+			continue
+		}
+
+		found := false
+		for _, val := range vals {
+			if val == parts[1] {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// readHeaderFile reads HTTP style Key: Value header lines from file, blank lines and lines starting with # are skipped
+func readHeaderFile(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var hdrs []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		hdrs = append(hdrs, line)
+	}
+
+	return hdrs, scanner.Err()
+}
+
 func loadContext() error {
 	config, ctxError = natscontext.New(cfgCtx, !skipContexts,
 		natscontext.WithServerURL(servers),
@@ -455,8 +931,29 @@ func loadContext() error {
 	return ctxError
 }
 
-func prepareConfig(_ *kingpin.ParseContext) (err error) {
+func prepareConfig(pc *kingpin.ParseContext) (err error) {
 	loadContext()
 
+	// the context timeout applies unless one was specifically requested
+	if config != nil && config.Timeout() > 0 && !flagGiven(pc, "timeout") && os.Getenv("NATS_TIMEOUT") == "" {
+		timeout = config.Timeout()
+	}
+
 	return nil
 }
+
+// flagGiven determines if the flag name was set on the command line
+func flagGiven(pc *kingpin.ParseContext, name string) bool {
+	if pc == nil {
+		return false
+	}
+
+	for _, e := range pc.Elements {
+		flag, ok := e.Clause.(*kingpin.FlagClause)
+		if ok && flag.Model().Name == name {
+			return true
+		}
+	}
+
+	return false
+}
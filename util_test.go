@@ -14,7 +14,21 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/nats-io/jsm.go/api"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
 )
 
 func checkErr(t *testing.T, err error, format string, a ...interface{}) {
@@ -80,3 +94,237 @@ func TestParseDurationString(t *testing.T) {
 		t.Fatalf("expected 1.1 hour from 1.1h duration, got %v", d)
 	}
 }
+
+func TestParseStringsToHeader(t *testing.T) {
+	msg := nats.NewMsg("test")
+	err := parseStringsToHeader([]string{"X-Foo: bar", " X-Bar :baz "}, msg)
+	checkErr(t, err, "parsing headers failed: %s", err)
+	if msg.Header.Get("X-Foo") != "bar" || msg.Header.Get("X-Bar") != "baz" {
+		t.Fatalf("invalid headers parsed: %v", msg.Header)
+	}
+
+	for _, hdr := range []string{"foo", ":foo"} {
+		err = parseStringsToHeader([]string{hdr}, nats.NewMsg("test"))
+		if err == nil || err.Error() != fmt.Sprintf("header %q is invalid, expected Key:Value", hdr) {
+			t.Fatalf("expected header %q to fail, got %v", hdr, err)
+		}
+	}
+}
+
+func TestMsgMatchesHeaders(t *testing.T) {
+	msg := nats.NewMsg("test")
+	msg.Header.Add("X-Foo", "bar")
+	msg.Header.Add("X-Baz", "1")
+
+	for _, filters := range [][]string{nil, {"X-Foo=bar"}, {"x-foo=bar", "X-Baz="}, {"X-Baz=1"}} {
+		if !msgMatchesHeaders(msg, filters) {
+			t.Fatalf("expected %v to match", filters)
+		}
+	}
+
+	for _, filters := range [][]string{{"X-Foo=baz"}, {"X-Missing="}, {"X-Foo=bar", "X-Baz=2"}} {
+		if msgMatchesHeaders(msg, filters) {
+			t.Fatalf("expected %v to not match", filters)
+		}
+	}
+}
+
+func TestSubjectsCollide(t *testing.T) {
+	for _, pair := range [][2]string{{"foo", "foo"}, {"orders.*", "orders.>"}, {"orders.new", "orders.*"}, {"foo.*.baz", "foo.bar.*"}, {">", "foo.bar"}, {"foo.>", "foo.bar.baz"}} {
+		if !subjectsCollide(pair[0], pair[1]) || !subjectsCollide(pair[1], pair[0]) {
+			t.Fatalf("expected %q and %q to collide", pair[0], pair[1])
+		}
+	}
+
+	for _, pair := range [][2]string{{"foo", "bar"}, {"foo.>", "foo"}, {"foo.*", "foo.bar.baz"}, {"orders.*", "invoices.>"}, {"foo.bar", "foo"}} {
+		if subjectsCollide(pair[0], pair[1]) || subjectsCollide(pair[1], pair[0]) {
+			t.Fatalf("expected %q and %q to not collide", pair[0], pair[1])
+		}
+	}
+}
+
+func TestTraceRedact(t *testing.T) {
+	line := traceRedact.ReplaceAllString(`CONNECT {"user":"bob","pass":"secret","echo":true}`, `"$1":"[redacted]"`)
+	if line != `CONNECT {"user":"bob","pass":"[redacted]","echo":true}` {
+		t.Fatalf("password was not redacted: %s", line)
+	}
+}
+
+func TestSubjectRemap(t *testing.T) {
+	for _, r := range []string{"foo", "foo:", ":bar", "foo.*:bar", "foo.>:bar.*"} {
+		_, err := parseSubjectRemap(r)
+		if err == nil {
+			t.Fatalf("expected %q to be invalid", r)
+		}
+	}
+
+	cases := []struct {
+		remap   string
+		subject string
+		result  string
+		matched bool
+	}{
+		{"js.file.>:js.restored.>", "js.file.>", "js.restored.>", true},
+		{"js.file.>:js.restored.>", "js.file.one.two", "js.restored.one.two", true},
+		{"js.file.>:js.restored.>", "js.file", "", false},
+		{"orders.*.new:eu.orders.*.new", "orders.acme.new", "eu.orders.acme.new", true},
+		{"orders.*.new:eu.orders.*.new", "orders.acme.old", "", false},
+		{"orders.*:eu.orders.*", "orders.acme.new", "", false},
+		{"orders:invoices", "orders", "invoices", true},
+		{"orders:invoices", "orders.new", "", false},
+	}
+
+	for _, tc := range cases {
+		remap, err := parseSubjectRemap(tc.remap)
+		checkErr(t, err, "parse failed: %v", err)
+
+		result, matched := remap.Apply(tc.subject)
+		if matched != tc.matched || result != tc.result {
+			t.Fatalf("expected %q remapped by %q to be %q (%v) got %q (%v)", tc.subject, tc.remap, tc.result, tc.matched, result, matched)
+		}
+	}
+}
+
+func TestPrettyJSONBody(t *testing.T) {
+	body := prettyJSONBody([]byte(`{"a":1,"b":[true]}`))
+	if string(body) != "{\n  \"a\": 1,\n  \"b\": [\n    true\n  ]\n}" {
+		t.Fatalf("unexpected pretty output: %q", body)
+	}
+
+	body = prettyJSONBody([]byte("not json"))
+	if string(body) != "not json" {
+		t.Fatalf("expected non JSON body to be unchanged, got %q", body)
+	}
+}
+
+func TestInlineAuthOpts(t *testing.T) {
+	opts, err := inlineAuthOpts("", "")
+	if err != nil || len(opts) != 0 {
+		t.Fatalf("expected no options without credentials, got %v, %v", opts, err)
+	}
+
+	_, err = inlineAuthOpts("x.y.z", "")
+	if err == nil {
+		t.Fatalf("expected a JWT without seed to fail")
+	}
+
+	_, err = inlineAuthOpts("", "invalid")
+	if err == nil {
+		t.Fatalf("expected an invalid seed to fail")
+	}
+
+	kp, err := nkeys.CreateUser()
+	checkErr(t, err, "could not create nkey: %v", err)
+	seed, err := kp.Seed()
+	checkErr(t, err, "could not get seed: %v", err)
+
+	opts, err = inlineAuthOpts("", string(seed))
+	checkErr(t, err, "seed failed: %v", err)
+	if len(opts) != 1 {
+		t.Fatalf("expected 1 option got %d", len(opts))
+	}
+}
+
+func TestDecompressBody(t *testing.T) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte("hello world"))
+	w.Close()
+
+	body := decompressBody("gzip", buf.Bytes())
+	if string(body) != "hello world" {
+		t.Fatalf("expected decompressed body got %q", body)
+	}
+
+	body = decompressBody("gzip", []byte("not compressed"))
+	if string(body) != "not compressed" {
+		t.Fatalf("expected invalid data to be returned unchanged got %q", body)
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	checkErr(t, err, "temp dir failed: %v", err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "consumer.yaml")
+	err = ioutil.WriteFile(file, []byte("durable_name: ORDERS\ndeliver_policy: all\nack_policy: explicit\nreplay_policy: instant\nmax_deliver: 5\n"), 0600)
+	checkErr(t, err, "write failed: %v", err)
+
+	cfg := api.ConsumerConfig{}
+	err = loadConfigFile(file, cfg.SchemaType(), &cfg)
+	checkErr(t, err, "load failed: %v", err)
+	if cfg.Durable != "ORDERS" || cfg.AckPolicy != api.AckExplicit || cfg.MaxDeliver != 5 {
+		t.Fatalf("invalid config loaded: %+v", cfg)
+	}
+
+	err = ioutil.WriteFile(file, []byte(`{"deliver_policy": "all", "ack_policy": "explicit", "replay_policy": "instant", "max_deliver": "5"}`), 0600)
+	checkErr(t, err, "write failed: %v", err)
+
+	err = loadConfigFile(file, cfg.SchemaType(), &cfg)
+	if err == nil || !strings.Contains(err.Error(), "$.max_deliver:") {
+		t.Fatalf("expected a max_deliver validation error got %v", err)
+	}
+}
+
+func TestProtoEncodeDecode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	checkErr(t, err, "temp dir failed: %v", err)
+	defer os.RemoveAll(dir)
+
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:    proto.String("test.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Order"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("item"), JsonName: proto.String("item"), Number: proto.Int32(1), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()},
+				{Name: proto.String("count"), JsonName: proto.String("count"), Number: proto.Int32(2), Type: descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()},
+			},
+		}},
+	}}}
+
+	sb, err := proto.Marshal(set)
+	checkErr(t, err, "marshal failed: %v", err)
+	file := filepath.Join(dir, "test.pb")
+	err = ioutil.WriteFile(file, sb, 0600)
+	checkErr(t, err, "write failed: %v", err)
+
+	_, err = protoMessageType(file, "test.Missing")
+	if err == nil {
+		t.Fatalf("expected an unknown message to fail")
+	}
+
+	md, err := protoMessageType(file, "test.Order")
+	checkErr(t, err, "lookup failed: %v", err)
+
+	wire, err := protoEncode(md, []byte(`{"item": "widget", "count": 2}`))
+	checkErr(t, err, "encode failed: %v", err)
+
+	_, err = protoEncode(md, []byte(`{"color": "red"}`))
+	if err == nil {
+		t.Fatalf("expected an unknown field to fail")
+	}
+
+	var decoded map[string]interface{}
+	err = json.Unmarshal(protoDecode(md, wire), &decoded)
+	checkErr(t, err, "invalid JSON decoded: %v", err)
+	if decoded["item"] != "widget" || decoded["count"] != float64(2) {
+		t.Fatalf("invalid message decoded: %v", decoded)
+	}
+}
+
+func TestCheckServerURLs(t *testing.T) {
+	for _, s := range []string{"nats://localhost:4222", "localhost", "tls://a:4222, nats://b:4222"} {
+		if err := checkServerURLs(s); err != nil {
+			t.Fatalf("%s failed: %s", s, err)
+		}
+	}
+
+	for _, s := range []string{"ws://localhost:8080", "nats://a:4222,WSS://b:443"} {
+		if err := checkServerURLs(s); err == nil {
+			t.Fatalf("%s did not fail", s)
+		}
+	}
+}